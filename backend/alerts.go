@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// recordAlert assigns the alert its sequence number and keeps it so clients that
+// were disconnected can catch up
+func recordAlert(alert *PriceAlert) {
+	if err := store.AddAlert(alert); err != nil {
+		log.Printf("Failed to store alert for %s: %v", alert.ID, err)
+	}
+}
+
+// Get unread alerts handler
+func getUnreadAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	clientID := r.URL.Query().Get("clientId")
+	if !validID.MatchString(clientID) {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidID, "clientId query parameter is required")
+		return
+	}
+
+	unread, err := store.UnreadAlerts(clientID)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"alerts":  unread,
+	})
+}
+
+// Acknowledge alerts handler. Marks every alert up to and including Seq as read.
+func ackAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		ClientID string `json:"clientId"`
+		Seq      int64  `json:"seq"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var problems validationErrors
+	if !validID.MatchString(req.ClientID) {
+		problems.add("clientId", ErrCodeInvalidID, "Invalid clientId")
+	}
+	if req.Seq < 0 {
+		problems.add("seq", ErrCodeInvalidParameter, "seq cannot be negative")
+	}
+	if err := problems.err(); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	acked, err := store.AckAlerts(req.ClientID, req.Seq)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"seq":     acked,
+	})
+}
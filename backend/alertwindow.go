@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// parseWindowTime reads an alert window bound: an RFC 3339 time, or a date such
+// as 2025-10-01 meaning the start of that day, or with endOfDay its end
+func parseWindowTime(s string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid alert window time %q, expected a date such as 2025-10-01 or an RFC 3339 time", s)
+	}
+	if endOfDay {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, nil
+}
+
+// resolveAlertWindow validates an item's alert window and stores its bounds as
+// RFC 3339 times
+func resolveAlertWindow(item *TrackingRequest) error {
+	if item.AlertWindowStart == "" && item.AlertWindowEnd == "" {
+		if item.AlertWindowPause {
+			return fmt.Errorf("alertWindowPause needs alertWindowStart or alertWindowEnd")
+		}
+		return nil
+	}
+	var start, end time.Time
+	var err error
+	if item.AlertWindowStart != "" {
+		if start, err = parseWindowTime(item.AlertWindowStart, false); err != nil {
+			return err
+		}
+		item.AlertWindowStart = start.Format(time.RFC3339)
+	}
+	if item.AlertWindowEnd != "" {
+		if end, err = parseWindowTime(item.AlertWindowEnd, true); err != nil {
+			return err
+		}
+		item.AlertWindowEnd = end.Format(time.RFC3339)
+	}
+	if !start.IsZero() && !end.IsZero() && !end.After(start) {
+		return fmt.Errorf("alertWindowEnd must be after alertWindowStart")
+	}
+	return nil
+}
+
+// inAlertWindow reports whether alerts for the item may be sent at now. Items
+// without a window always alert.
+func inAlertWindow(item TrackingRequest, now time.Time) bool {
+	if item.AlertWindowStart != "" {
+		if start, err := time.Parse(time.RFC3339, item.AlertWindowStart); err == nil && now.Before(start) {
+			return false
+		}
+	}
+	if item.AlertWindowEnd != "" {
+		if end, err := time.Parse(time.RFC3339, item.AlertWindowEnd); err == nil && !now.Before(end) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPausedOutsideWindow reports whether the monitor should skip the item
+// entirely, rather than check it silently
+func isPausedOutsideWindow(item TrackingRequest, now time.Time) bool {
+	return item.AlertWindowPause && !inAlertWindow(item, now)
+}
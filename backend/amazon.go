@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gocolly/colly/v2"
+
+	"price-tracker-backend/currency"
+)
+
+// Product URL paths that carry an ASIN, e.g. /Some-Name/dp/B0ABCDEF12/ref=...
+var asinPattern = regexp.MustCompile(`/(?:dp|gp/product|gp/aw/d|gp/offer-listing)/([A-Z0-9]{10})(?:[/?]|$)`)
+
+// Markers of a listing whose price is only shown under "See All Buying Options"
+const buyingOptionsSelectors = "#buybox-see-all-buying-choices, #buybox-see-all-buying-choices-announce, a[href*='/gp/offer-listing/']"
+
+// Offer prices on the offer-listing page, old and current layouts
+const offerPriceSelectors = ".olpOfferPrice, #aod-pinned-offer .a-price .a-offscreen, #aod-offer .a-price .a-offscreen"
+
+// amazonASIN extracts the ASIN from an Amazon product URL, or returns ""
+func amazonASIN(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || !strings.Contains(strings.ToLower(u.Hostname()), "amazon.") {
+		return ""
+	}
+	m := asinPattern.FindStringSubmatch(u.Path)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// offerListingURL is the page behind "See All Buying Options" for an ASIN
+func offerListingURL(productURL, asin string) (string, error) {
+	u, err := url.Parse(productURL)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s://%s/gp/aod/ajax/?asin=%s&pc=dp", u.Scheme, u.Host, asin), nil
+}
+
+// fetchLowestOffer scrapes the offer listing for an ASIN and returns the lowest
+// offer price along with the string it was parsed from
+func fetchLowestOffer(ctx context.Context, productURL, asin string) (string, currency.Money, error) {
+	listingURL, err := offerListingURL(productURL, asin)
+	if err != nil {
+		return "", currency.Money{}, err
+	}
+
+	c := newCollector(ctx, listingURL)
+	format := priceFormatFor(listingURL)
+	var lowestString string
+	var lowest currency.Money
+	c.OnHTML(offerPriceSelectors, func(e *colly.HTMLElement) {
+		text := strings.TrimSpace(e.Text)
+		price, err := parseScrapedPrice(text, format)
+		if err != nil || price.Sign() <= 0 {
+			return
+		}
+		if lowestString == "" || price.Cmp(lowest) < 0 {
+			lowestString, lowest = text, price
+		}
+	})
+
+	if err := c.Visit(listingURL); err != nil {
+		return "", currency.Money{}, err
+	}
+	if lowestString == "" {
+		return "", currency.Money{}, fmt.Errorf("no offers found on %s", listingURL)
+	}
+	logf(ctx, "Lowest offer for %s: %s", asin, lowestString)
+	return lowestString, lowest, nil
+}
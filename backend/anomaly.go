@@ -0,0 +1,57 @@
+package main
+
+import (
+	"math"
+
+	"price-tracker-backend/currency"
+)
+
+// Fewest earlier prices needed before a price can be called an outlier
+const minAnomalySamples = 5
+
+// priceZScore compares price with the last window prices in history and returns
+// how many standard deviations it lies from their mean. ok is false when there
+// isn't enough history or the prices haven't varied at all.
+func priceZScore(history []PricePoint, price currency.Money, window int) (z float64, ok bool) {
+	if window > 0 && len(history) > window {
+		history = history[len(history)-window:]
+	}
+	if len(history) < minAnomalySamples {
+		return 0, false
+	}
+
+	var sum, sumSquares float64
+	for _, p := range history {
+		converted := p.ConvertedPrice.Float()
+		sum += converted
+		sumSquares += converted * converted
+	}
+	n := float64(len(history))
+	mean := sum / n
+	stddev := math.Sqrt(math.Max(sumSquares/n-mean*mean, 0))
+	if stddev < 1e-9 {
+		return 0, false
+	}
+	return (price.Float() - mean) / stddev, true
+}
+
+// checkAnomaly flags an item whose new price is an outlier against its recent
+// history, and returns true if the item just became anomalous
+func checkAnomaly(id string, history []PricePoint, price currency.Money) bool {
+	if cfg.AnomalyStdDevs <= 0 {
+		return false
+	}
+	z, ok := priceZScore(history, price, cfg.AnomalyWindow)
+	anomaly := ok && math.Abs(z) > cfg.AnomalyStdDevs
+
+	became := false
+	updateItemState(id, func(item *TrackingRequest) {
+		became = anomaly && !item.Anomaly
+		item.Anomaly = anomaly
+		item.AnomalyScore = 0
+		if ok {
+			item.AnomalyScore = math.Round(z*100) / 100
+		}
+	})
+	return became
+}
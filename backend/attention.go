@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// needsAttention reports whether a failed check, already counted in the item's
+// ConsecutiveFailures, shows a tracker that found prices before and stopped,
+// which usually means the site changed. Items that never found a price are
+// left alone: their URL or domain config is more likely wrong from the start.
+func needsAttention(item TrackingRequest, err error) bool {
+	return cfg.PriceNotFoundAlertAfter > 0 &&
+		errors.Is(err, errPriceNotFound) &&
+		item.LastSuccessAt != "" &&
+		!item.NeedsAttention &&
+		item.ConsecutiveFailures >= cfg.PriceNotFoundAlertAfter
+}
+
+// notifyNeedsAttention alerts that an item's price is no longer found
+func notifyNeedsAttention(item TrackingRequest) {
+	logf(context.Background(), "No price found for %s in %d checks since %s; it needs attention", item.ID, item.ConsecutiveFailures, item.LastSuccessAt)
+	deliverAlert(context.Background(), PriceAlert{
+		ID:             item.ID,
+		URL:            item.URL,
+		Locale:         item.Locale,
+		Title:          item.Title,
+		ImageURL:       item.ImageURL,
+		TargetPrice:    alertTarget(item),
+		ConvertedPrice: item.LastPrice,
+		BaseCurrency:   converter.Base,
+		Type:           AlertNeedsAttention,
+		Timestamp:      time.Now().Format(time.RFC3339),
+	})
+}
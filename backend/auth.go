@@ -0,0 +1,29 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// bearerAuthorized reports whether a request carries token as its bearer token
+func bearerAuthorized(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// requireAdmin answers 403 when ADMIN_TOKEN isn't set and 401 when the request
+// doesn't carry it, for endpoints that expose operational details. It returns
+// true when the request may go on.
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if cfg.AdminToken == "" {
+		writeError(w, http.StatusForbidden, ErrCodeForbidden, "Admin endpoints are disabled; set ADMIN_TOKEN to enable them")
+		return false
+	}
+	if !bearerAuthorized(r, cfg.AdminToken) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "A valid bearer token is required")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package main
+
+import "price-tracker-backend/currency"
+
+// How an item's target price and minimum discount from baseline combine when
+// both are set
+const (
+	TargetAny = "any" // Either one reached alerts (the default)
+	TargetAll = "all" // Both must be reached
+)
+
+// validTargetCondition reports whether s is a known TargetCondition, or empty for the default
+func validTargetCondition(s string) bool {
+	return s == "" || s == TargetAny || s == TargetAll
+}
+
+// baselineTarget is the price MinDiscountFromBaselinePercent below the item's
+// baseline, or 0 when it isn't set or there's no baseline yet
+func baselineTarget(item TrackingRequest) currency.Money {
+	if item.MinDiscountFromBaselinePercent <= 0 || item.BaselinePrice.Sign() <= 0 {
+		return currency.Money{}
+	}
+	return item.BaselinePrice.Mul(1 - item.MinDiscountFromBaselinePercent/100)
+}
+
+// alertTarget is the price, in the base currency, at or below which an item
+// alerts: its target price, its discount from baseline, or with both set the
+// higher of the two (TargetAny) or the lower (TargetAll). It's 0 when the item
+// can't alert yet, e.g. while its baseline is unknown.
+func alertTarget(item TrackingRequest) currency.Money {
+	discount := baselineTarget(item)
+	switch {
+	case item.MinDiscountFromBaselinePercent <= 0:
+		return item.TargetPrice
+	case item.TargetPrice.Sign() <= 0:
+		return discount
+	case item.TargetCondition == TargetAll:
+		if discount.Sign() <= 0 {
+			return currency.Money{}
+		}
+		return currency.Min(item.TargetPrice, discount)
+	}
+	return currency.Max(item.TargetPrice, discount)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"    // Scrapes go through
+	BreakerOpen     = "open"      // Scrapes fail fast until the cooldown ends
+	BreakerHalfOpen = "half-open" // One trial scrape is let through to test recovery
+)
+
+var errCircuitOpen = errors.New("circuit breaker open")
+
+// domainBreaker tracks consecutive failures for one domain
+type domainBreaker struct {
+	State    string    `json:"state"`
+	Failures int       `json:"consecutiveFailures"`
+	OpenedAt time.Time `json:"openedAt,omitzero"`
+	// Set when the domain answered 429; it isn't scraped until then, see throttleDomain
+	ThrottledUntil time.Time `json:"throttledUntil,omitzero"`
+	trialInFlight  bool
+}
+
+var (
+	breakers  = make(map[string]*domainBreaker)
+	breakerMu sync.Mutex
+)
+
+func breakerKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// breakerAllow reports whether a scrape of rawURL may proceed
+func breakerAllow(rawURL string) error {
+	key := breakerKey(rawURL)
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+
+	b, ok := breakers[key]
+	if !ok {
+		return nil
+	}
+	if time.Now().Before(b.ThrottledUntil) {
+		return fmt.Errorf("%w: %s, retrying after %s", errThrottled, key, b.ThrottledUntil.Format(time.RFC3339))
+	}
+	if cfg.BreakerFailureThreshold <= 0 {
+		return nil
+	}
+	switch b.State {
+	case BreakerOpen:
+		if time.Since(b.OpenedAt) < cfg.BreakerCooldown {
+			return fmt.Errorf("%w for %s, retrying after %s", errCircuitOpen, key, b.OpenedAt.Add(cfg.BreakerCooldown).Format(time.RFC3339))
+		}
+		log.Printf("Circuit breaker for %s half-open, trying one request", key)
+		b.State = BreakerHalfOpen
+		b.trialInFlight = true
+		return nil
+	case BreakerHalfOpen:
+		if b.trialInFlight {
+			return fmt.Errorf("%w for %s, recovery check in progress", errCircuitOpen, key)
+		}
+		b.trialInFlight = true
+	}
+	return nil
+}
+
+// breakerRecord updates the domain's breaker with the outcome of a scrape
+func breakerRecord(rawURL string, err error) {
+	if cfg.BreakerFailureThreshold <= 0 {
+		return
+	}
+	key := breakerKey(rawURL)
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+
+	b, ok := breakers[key]
+	if !ok {
+		b = &domainBreaker{State: BreakerClosed}
+		breakers[key] = b
+	}
+	b.trialInFlight = false
+
+	if err == nil {
+		if b.State != BreakerClosed {
+			log.Printf("Circuit breaker for %s closed", key)
+		}
+		b.State = BreakerClosed
+		b.Failures = 0
+		return
+	}
+
+	b.Failures++
+	if b.State == BreakerHalfOpen || b.Failures >= cfg.BreakerFailureThreshold {
+		if b.State != BreakerOpen {
+			log.Printf("Circuit breaker for %s opened after %d consecutive failures", key, b.Failures)
+		}
+		b.State = BreakerOpen
+		b.OpenedAt = time.Now()
+	}
+}
+
+// breakerAbandon gives up a scrape that breakerAllow let through but that never
+// reached the domain, e.g. disallowed by robots.txt or cancelled while waiting
+// for its turn. It counts as neither a failure nor a success, but lets the next
+// scrape make the half-open breaker's trial.
+func breakerAbandon(rawURL string) {
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+	if b, ok := breakers[breakerKey(rawURL)]; ok {
+		b.trialInFlight = false
+	}
+}
+
+// breakerStates returns a snapshot of every domain's breaker
+func breakerStates() map[string]domainBreaker {
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+	states := make(map[string]domainBreaker, len(breakers))
+	for key, b := range breakers {
+		states[key] = *b
+	}
+	return states
+}
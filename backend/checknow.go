@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Earliest time each item may be checked on demand again
+var (
+	nextManualCheck   = make(map[string]time.Time)
+	nextManualCheckMu sync.Mutex
+)
+
+// allowManualCheck reports whether an on-demand check of id may run now, and
+// if so blocks further ones for MANUAL_CHECK_INTERVAL
+func allowManualCheck(id string, now time.Time) (bool, time.Duration) {
+	nextManualCheckMu.Lock()
+	defer nextManualCheckMu.Unlock()
+	if next := nextManualCheck[id]; now.Before(next) {
+		return false, next.Sub(now)
+	}
+	for other, next := range nextManualCheck {
+		if !now.Before(next) {
+			delete(nextManualCheck, other)
+		}
+	}
+	nextManualCheck[id] = now.Add(cfg.ManualCheckInterval)
+	return true, 0
+}
+
+// checkItemHandler checks one tracked item right away, the same way the monitor
+// does: its state and history are updated and alerts are sent. The response is
+// the item afterwards and the price just recorded.
+func checkItemHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+	item, err := store.GetItem(id)
+	if errors.Is(err, errItemNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("No tracked item with ID %q", id))
+		return
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	if ok, wait := allowManualCheck(id, time.Now()); !ok {
+		w.Header().Set("Retry-After", fmt.Sprint(int(wait.Seconds())+1))
+		writeError(w, http.StatusTooManyRequests, ErrCodeRateLimited, fmt.Sprintf("Item %s can be checked again in %s", id, wait.Round(time.Second)))
+		return
+	}
+	if !acquireCheckLease(id) {
+		writeError(w, http.StatusConflict, ErrCodeCheckInProgress, fmt.Sprintf("Item %s is being checked by another instance", id))
+		return
+	}
+	logf(r.Context(), "On-demand check of %s requested", id)
+	started := time.Now().Format(time.RFC3339)
+	checkAndNotify(withFreshScrape(context.WithoutCancel(r.Context())), id, item)
+	finishCheckLease(id)
+
+	response := map[string]interface{}{"success": true}
+	if history, _, err := store.History(id); err == nil && len(history) > 0 {
+		if latest := history[len(history)-1]; latest.Timestamp >= started {
+			response["price"] = latest
+		}
+	}
+	checked, err := store.GetItem(id)
+	switch {
+	case errors.Is(err, errItemNotFound):
+		// The check alerted and tracking stopped
+		response["item"], response["untracked"] = item, true
+	case err != nil:
+		writeAPIError(w, err)
+		return
+	case checked.ConsecutiveFailures > 0:
+		writeAPIError(w, lastCheckError(checked))
+		return
+	default:
+		response["item"], response["status"] = withDealRating(checked), itemStatus(checked)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// lastCheckError reports an item's failed check as scrapeAPIError did, from
+// the error stored on the item
+func lastCheckError(item TrackingRequest) *APIError {
+	switch item.LastErrorCode {
+	case ErrCodePriceNotFound:
+		return newAPIError(http.StatusUnprocessableEntity, ErrCodePriceNotFound, fmt.Sprintf("No usable price on the page: %s", item.LastError))
+	case ErrCodeBlockedURL:
+		return newAPIError(http.StatusBadRequest, ErrCodeBlockedURL, item.LastError)
+	default:
+		return newAPIError(http.StatusBadGateway, ErrCodeScrapeFailed, fmt.Sprintf("Unable to fetch price: %s", item.LastError))
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// compactHistory periodically thins out old price history: points younger than
+// HISTORY_MINUTELY_FOR keep one per minute, then one per hour until
+// HISTORY_HOURLY_FOR, then one per day, and points past HISTORY_RETENTION go.
+// The history of untracked items is compacted too, and goes entirely once
+// it's past retention.
+func compactHistory() {
+	if cfg.HistoryCompactInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(cfg.HistoryCompactInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ids, err := store.HistoryIDs()
+		if err != nil {
+			log.Printf("History compaction failed to list histories: %v", err)
+			continue
+		}
+		items, err := store.ListItems()
+		if err != nil {
+			log.Printf("History compaction failed to list items: %v", err)
+			continue
+		}
+		tracked := make(map[string]bool, len(items))
+		for _, item := range items {
+			tracked[item.ID] = true
+		}
+		now := time.Now()
+		total := 0
+		for _, id := range ids {
+			removed, err := store.PruneHistory(id, func(points []PricePoint) []bool {
+				return historyToKeep(points, now, tracked[id])
+			})
+			if err != nil {
+				log.Printf("History compaction failed for %s: %v", id, err)
+				continue
+			}
+			total += removed
+		}
+		if total > 0 {
+			log.Printf("History compaction removed %d points across %d histories", total, len(ids))
+		}
+	}
+}
+
+// historyToKeep decides which points survive compaction. Within each bucket the
+// lowest price is kept (the latest of equal ones) so deals aren't lost, and a
+// tracked item's most recent point is always kept since it's its current price.
+func historyToKeep(points []PricePoint, now time.Time, tracked bool) []bool {
+	keep := make([]bool, len(points))
+	type bucket struct {
+		size     time.Duration
+		start    int64
+		currency string
+	}
+	best := map[bucket]int{} // Index of the point kept for each bucket
+	for i, p := range points {
+		ts, err := time.Parse(time.RFC3339, p.Timestamp)
+		if err != nil {
+			keep[i] = true // Leave points we can't place alone
+			continue
+		}
+		age := now.Sub(ts)
+		if cfg.HistoryRetention > 0 && age > cfg.HistoryRetention {
+			continue
+		}
+
+		var size time.Duration
+		switch {
+		case age <= cfg.HistoryMinutelyFor:
+			size = time.Minute
+		case age <= cfg.HistoryHourlyFor:
+			size = time.Hour
+		default:
+			size = 24 * time.Hour
+		}
+		// Lowest prices in different currencies can't be compared, so each keeps its own
+		b := bucket{size, ts.Truncate(size).Unix(), comparisonCurrency(p)}
+		if j, ok := best[b]; !ok || p.ConvertedPrice.Cmp(points[j].ConvertedPrice) <= 0 {
+			best[b] = i
+		}
+	}
+	for _, i := range best {
+		keep[i] = true
+	}
+	if len(points) > 0 && tracked {
+		keep[len(points)-1] = true
+	}
+	return keep
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"price-tracker-backend/currency"
+)
+
+// Limits of GET /api/compare
+const (
+	maxCompareItems   = 10
+	maxCompareBuckets = 1000
+)
+
+// Bucket sizes picked from when the request doesn't name one, smallest first
+var compareBucketSizes = []time.Duration{
+	time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour, 6 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour,
+}
+
+// ComparePoint is one time bucket of a comparison: each item's price then, in
+// the comparison's currency, or nil before the item's first price
+type ComparePoint struct {
+	Timestamp string                     `json:"timestamp"` // Start of the bucket
+	Prices    map[string]*currency.Money `json:"prices"`
+}
+
+// autoBucketSize is the smallest bucket size that fits the span in a couple of
+// hundred buckets
+func autoBucketSize(span time.Duration) time.Duration {
+	for _, size := range compareBucketSizes {
+		if span/size <= 200 {
+			return size
+		}
+	}
+	return compareBucketSizes[len(compareBucketSizes)-1]
+}
+
+// alignHistories puts the histories on a common time axis of size buckets.
+// An item's price in a bucket is its lowest there, like compaction keeps, and
+// buckets without a point carry its previous price forward so the series can
+// be overlaid.
+func alignHistories(ids []string, histories map[string][]PricePoint, size time.Duration) []ComparePoint {
+	lowest := make(map[string]map[int64]currency.Money, len(ids))
+	var first, last int64
+	found := false
+	for _, id := range ids {
+		lowest[id] = map[int64]currency.Money{}
+		for _, p := range histories[id] {
+			ts, err := time.Parse(time.RFC3339, p.Timestamp)
+			if err != nil {
+				continue
+			}
+			b := ts.Truncate(size).Unix()
+			if price, ok := lowest[id][b]; !ok || p.ConvertedPrice.Cmp(price) < 0 {
+				lowest[id][b] = p.ConvertedPrice
+			}
+			if !found || b < first {
+				first = b
+			}
+			if !found || b > last {
+				last = b
+			}
+			found = true
+		}
+	}
+	if !found {
+		return []ComparePoint{}
+	}
+
+	step := int64(size / time.Second)
+	current := make(map[string]*currency.Money, len(ids))
+	points := make([]ComparePoint, 0, (last-first)/step+1)
+	for b := first; b <= last; b += step {
+		point := ComparePoint{Timestamp: time.Unix(b, 0).UTC().Format(time.RFC3339), Prices: make(map[string]*currency.Money, len(ids))}
+		for _, id := range ids {
+			if price, ok := lowest[id][b]; ok {
+				current[id] = &price
+			}
+			point.Prices[id] = current[id]
+		}
+		points = append(points, point)
+	}
+	return points
+}
+
+// compareHandler returns the price histories of several items aligned on one
+// time axis, e.g. to overlay the same product at different retailers:
+// GET /api/compare?ids=a,b&bucket=1h. Prices are in the base currency, or in
+// the first item's latest currency without one; points in other currencies
+// that can't be converted are left out.
+func compareHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	var ids []string
+	for _, id := range strings.Split(query.Get("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	var problems validationErrors
+	switch {
+	case len(ids) < 2:
+		problems.add("ids", ErrCodeInvalidParameter, "ids must list at least two comma-separated item IDs")
+	case len(ids) > maxCompareItems:
+		problems.add("ids", ErrCodeInvalidParameter, fmt.Sprintf("At most %d items can be compared at once", maxCompareItems))
+	}
+	for _, id := range ids {
+		if !validID.MatchString(id) {
+			problems.add("ids", ErrCodeInvalidID, fmt.Sprintf("Invalid ID %q", id))
+		}
+	}
+	var size time.Duration
+	if s := query.Get("bucket"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < time.Minute {
+			problems.add("bucket", ErrCodeInvalidParameter, fmt.Sprintf("Invalid bucket %q, expected a duration of at least 1m such as \"1h\"", s))
+		}
+		size = d
+	}
+	if err := problems.err(); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	histories := make(map[string][]PricePoint, len(ids))
+	items := make([]map[string]interface{}, 0, len(ids))
+	code := converter.Base
+	var start, end time.Time
+	for _, id := range ids {
+		points, hasHistory, err := store.History(id)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		item, err := store.GetItem(id)
+		if err != nil && !errors.Is(err, errItemNotFound) {
+			writeAPIError(w, err)
+			return
+		}
+		tracked := err == nil
+		if !hasHistory && !tracked {
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("No tracked item or history for ID %q", id))
+			return
+		}
+		if code == "" && len(points) > 0 {
+			code = comparisonCurrency(points[len(points)-1])
+		}
+		points = comparableHistory(points, code)
+		histories[id] = points
+		items = append(items, map[string]interface{}{"id": id, "title": item.Title, "url": item.URL, "tracked": tracked})
+
+		for _, p := range points {
+			if ts, err := time.Parse(time.RFC3339, p.Timestamp); err == nil {
+				if start.IsZero() || ts.Before(start) {
+					start = ts
+				}
+				if ts.After(end) {
+					end = ts
+				}
+			}
+		}
+	}
+
+	if size == 0 {
+		size = autoBucketSize(end.Sub(start))
+	} else if end.Sub(start)/size >= maxCompareBuckets {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, fmt.Sprintf("bucket %s makes more than %d points for this history; use a larger one", size, maxCompareBuckets))
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"items":    items,
+		"currency": cmp.Or(code, converter.Base),
+		"bucket":   size.String(),
+		"points":   alignHistories(ids, histories, size),
+	})
+}
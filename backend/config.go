@@ -0,0 +1,396 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"price-tracker-backend/notify"
+	"price-tracker-backend/scraper"
+)
+
+// Config holds the server settings that can be changed through environment variables
+type Config struct {
+	ListenAddr       string // Address for plain HTTP or TLS with certificate files
+	TLSCertFile      string // Serve TLS when both cert and key files are set
+	TLSKeyFile       string
+	AutocertDomains  []string // Serve TLS on :443 with Let's Encrypt certificates for these domains
+	AutocertCacheDir string
+
+	HTTPReadHeaderTimeout time.Duration // Time allowed for reading a request's headers
+	HTTPReadTimeout       time.Duration // Time allowed for reading a whole request, 0 for no limit
+	HTTPWriteTimeout      time.Duration // Time allowed for handling a request and writing the response, 0 for no limit
+	HTTPIdleTimeout       time.Duration // How long an idle keep-alive connection stays open
+
+	BaseCurrency    string             // Currency all prices are normalized to for comparison, e.g. "INR"
+	CurrencyRates   map[string]float64 // Static rates: 1 unit of the key currency = value units of BaseCurrency
+	ExchangeRateURL string             // Optional API returning {"rates": {...}} relative to BaseCurrency
+
+	QuietHoursStart    string         // "HH:MM" when alert delivery is paused, empty to disable
+	QuietHoursEnd      string         // "HH:MM" when queued alerts are delivered
+	QuietHoursLocation *time.Location // Timezone the quiet-hours window is expressed in
+	UrgentDropPercent  float64        // Drops this far below target (in %) bypass quiet hours, 0 to disable
+
+	MaxTrackedItems   int           // Upper bound on concurrently tracked items, 0 for no limit
+	MinDropAmount     float64       // Default for items that don't set their own minimum drop below target
+	PriceEpsilon      float64       // Prices this close above a target still count as reaching it
+	AlertCooldown     time.Duration // Least time between two price alerts for the same item
+	AlertDedupeWindow time.Duration // Drop an alert identical to one sent within this, 0 to disable
+	// Prices within this percent of each other count as the same for deduplication, 0 for exact prices
+	AlertDedupePriceBucket float64
+	// Least time between two immediate check-price alerts for the same URL and target, 0 to disable
+	ImmediateAlertWindow time.Duration
+
+	AnomalyStdDevs  float64 // Flag prices this many standard deviations from recent history, 0 to disable
+	AnomalyWindow   int     // Recent history points the flag is computed over
+	NotifyAnomalies bool    // Send an "anomaly" alert when an item becomes flagged
+
+	UptrendChecks     int     // Price rises in a row that make an uptrend for items with notifyOnUptrend, 0 to disable
+	UptrendMinPercent float64 // Least total rise over those checks, in percent
+
+	PriceNotFoundAlertAfter int // Alert when an item that found prices before fails to find one this many checks in a row, 0 to disable
+
+	DealThresholdPercent float64 // Prices within this percent of the all-time low are good deals
+	NotifyLifecycle      bool    // Send a low-priority notification when a tracker starts or stops
+
+	HistoryCompactInterval time.Duration // How often price history is compacted, 0 to disable
+	HistoryMinutelyFor     time.Duration // Keep one point per minute for this long
+	HistoryHourlyFor       time.Duration // then one per hour until this age, then one per day
+	HistoryRetention       time.Duration // Delete points older than this, 0 to keep them forever
+
+	InitialScrapeOnTrack bool          // Scrape new items immediately instead of waiting for the next tick
+	InitialScrapeWait    time.Duration // How long /api/track-price waits for that scrape to report the first price
+	AlertLogSize         int           // Recent alerts kept for /api/alerts/unread, 0 or less for all
+
+	WSSendBuffer       int           // Alerts queued per WebSocket client
+	WSSendTimeout      time.Duration // How long a client with a full queue may lag before it's disconnected
+	WSCheckInterval    time.Duration // Least time between two on-demand checks from one WebSocket client
+	WSHandshakeTimeout time.Duration // Time allowed for writing the WebSocket handshake response
+
+	ManualCheckInterval time.Duration // Least time between two POST /api/tracked-items/{id}/check of one item
+	ScrapeCacheTTL      time.Duration // How long the monitor reuses a URL's scrape by a check endpoint, 0 to disable
+
+	IngestToken string // Bearer token external scrapers send to POST /api/ingest-price, empty to disable it
+	AdminToken  string // Bearer token for admin endpoints such as /api/proxies/status, empty to disable them
+
+	StoreBackend string // Where tracked items, history and alerts are kept: "memory" or "postgres"
+	DatabaseURL  string // Postgres DSN for the postgres store
+
+	StoreRetryInterval time.Duration // How often an unavailable store is probed before replaying buffered writes
+	StoreReplayBuffer  int           // Writes buffered while the store is unavailable, oldest dropped beyond this
+
+	MonitorStaleTicks int // Check intervals without a completed monitor tick before /api/health reports unhealthy
+
+	ItemLeases bool   // Lease items before checking so instances sharing a store don't double-scrape
+	InstanceID string // Identifies this instance as a lease owner
+
+	RateLimitDefault    string   // Requests per client IP and route, e.g. "120/m", for routes without their own limit
+	RateLimits          []string // Per-route limits such as "/api/check-price=10/m", over the built-in ones
+	RateLimitTrustProxy bool     // Identify clients by X-Forwarded-For, when behind a reverse proxy
+	RateLimitProxyHops  int      // Trusted proxies in front of the server, each appending to X-Forwarded-For
+
+	MaxRequestBodyBytes int64         // Largest accepted JSON request body
+	MaxScrapeBody       int64         // Largest scraped page or API response read, 0 for no limit
+	RequestReadTimeout  time.Duration // Time allowed for reading a request body, 0 for no limit
+
+	DomainConfigFile string // JSON file with per-domain scraping settings, see DomainConfig
+
+	BreakerFailureThreshold int           // Consecutive failures before a domain's circuit opens, 0 to disable
+	BreakerCooldown         time.Duration // How long an open circuit fails fast before a trial request
+
+	ThrottleCooldown    time.Duration // How long a domain that answered 429 without Retry-After isn't scraped
+	ThrottleMaxCooldown time.Duration // Longest pause a Retry-After can ask for
+
+	RobotsTxt         string        // "enforce" to skip paths robots.txt disallows, "ignore" to not fetch it
+	RobotsCacheTTL    time.Duration // How long a fetched robots.txt is reused
+	DomainConcurrency int           // Default limit on simultaneous scrapes per domain, 0 for none
+
+	FollowBuyingOptions bool // Read the lowest offer when an Amazon listing only shows "See All Buying Options"
+
+	ChromeURL string // DevTools URL of a running Chrome for headless scrapes, empty to start one locally
+
+	ScrapeAllowPrivate bool          // Allow scraping loopback, private and link-local addresses
+	ScrapeAllowedHosts []string      // Hosts scraped even if they resolve to internal addresses
+	ScrapeDeniedHosts  []string      // Hosts never scraped
+	HeadlessTimeout    time.Duration // How long a headless render may take
+
+	ScrapeProxies    []string      // Proxy URLs scrape requests rotate through, empty to connect directly
+	ProxyMaxFailures int           // Failures in a row that take a proxy out of rotation, 0 to never
+	ProxyCooldown    time.Duration // How long an unhealthy proxy is skipped before it's tried again
+
+	ScrapeDebug    bool   // Dump the fetched HTML when a scrape finds no price
+	ScrapeDebugDir string // Write full dumps to files here instead of logging a truncated snippet
+
+	ScraperUserAgent      string
+	ScraperAccept         string
+	ScraperAcceptLanguage string
+
+	NotifyLocale        string // Language of notifications for items without their own locale
+	NotifyTitleTemplate string // text/template for notification titles, see notify.AlertData
+	NotifyBodyTemplate  string
+
+	WebhookURL       string // Alerts are POSTed here as JSON
+	TelegramBotToken string
+	TelegramChatID   string
+	SMTPHost         string
+	SMTPPort         string
+	SMTPUsername     string
+	SMTPPassword     string
+	SMTPFrom         string
+	SMTPTo           []string
+
+	NotifyTimeout      time.Duration // How long one webhook or Telegram request may take
+	NotifyRetries      int           // Retries of a request that failed with a network error, 429 or 5xx
+	NotifyRetryBackoff time.Duration // Wait before the first retry, doubled for each next one
+	NotifyMaxRetryWait time.Duration // Longest wait between retries; a longer Retry-After gives up
+}
+
+var cfg = loadConfig()
+
+func loadConfig() Config {
+	return Config{
+		ListenAddr:       envString("LISTEN_ADDR", ":8080"),
+		TLSCertFile:      envString("TLS_CERT_FILE", ""),
+		TLSKeyFile:       envString("TLS_KEY_FILE", ""),
+		AutocertDomains:  envList("AUTOCERT_DOMAINS"),
+		AutocertCacheDir: envString("AUTOCERT_CACHE_DIR", "autocert-cache"),
+
+		HTTPReadHeaderTimeout: envDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		HTTPReadTimeout:       envDuration("HTTP_READ_TIMEOUT", 30*time.Second),
+		HTTPWriteTimeout:      envDuration("HTTP_WRITE_TIMEOUT", 2*time.Minute),
+		HTTPIdleTimeout:       envDuration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+
+		BaseCurrency:    strings.ToUpper(envString("BASE_CURRENCY", "")),
+		CurrencyRates:   envRates("CURRENCY_RATES"),
+		ExchangeRateURL: envString("EXCHANGE_RATE_URL", ""),
+
+		QuietHoursStart:    envString("QUIET_HOURS_START", ""),
+		QuietHoursEnd:      envString("QUIET_HOURS_END", ""),
+		QuietHoursLocation: envLocation("QUIET_HOURS_TZ"),
+		UrgentDropPercent:  envFloat("QUIET_HOURS_URGENT_DROP_PERCENT", 0),
+
+		MaxTrackedItems:   envInt("MAX_TRACKED_ITEMS", 500),
+		MinDropAmount:     envFloat("MIN_DROP_AMOUNT", 0),
+		PriceEpsilon:      envFloat("PRICE_EPSILON", 0.005),
+		AlertCooldown:     envDuration("ALERT_COOLDOWN", time.Hour),
+		AlertDedupeWindow: envDuration("ALERT_DEDUPE_WINDOW", 24*time.Hour),
+
+		AlertDedupePriceBucket: envFloat("ALERT_DEDUPE_PRICE_BUCKET", 0),
+		ImmediateAlertWindow:   envDuration("IMMEDIATE_ALERT_WINDOW", time.Minute),
+
+		AnomalyStdDevs:  envFloat("ANOMALY_STDDEVS", 3),
+		AnomalyWindow:   envInt("ANOMALY_WINDOW", 30),
+		NotifyAnomalies: envBool("NOTIFY_ANOMALIES", false),
+
+		UptrendChecks:     envInt("UPTREND_CHECKS", 3),
+		UptrendMinPercent: envFloat("UPTREND_MIN_PERCENT", 2),
+
+		PriceNotFoundAlertAfter: envInt("PRICE_NOT_FOUND_ALERT_AFTER", 3),
+
+		DealThresholdPercent: envFloat("DEAL_THRESHOLD_PERCENT", 5),
+		NotifyLifecycle:      envBool("NOTIFY_LIFECYCLE", false),
+
+		HistoryCompactInterval: envDuration("HISTORY_COMPACT_INTERVAL", time.Hour),
+		HistoryMinutelyFor:     envDuration("HISTORY_MINUTELY_FOR", 24*time.Hour),
+		HistoryHourlyFor:       envDuration("HISTORY_HOURLY_FOR", 7*24*time.Hour),
+		HistoryRetention:       envDuration("HISTORY_RETENTION", 0),
+
+		InitialScrapeOnTrack: envBool("INITIAL_SCRAPE_ON_TRACK", true),
+		InitialScrapeWait:    envDuration("INITIAL_SCRAPE_WAIT", 15*time.Second),
+		AlertLogSize:         envInt("ALERT_LOG_SIZE", 1000),
+
+		WSSendBuffer:       envInt("WS_SEND_BUFFER", 256),
+		WSSendTimeout:      envDuration("WS_SEND_TIMEOUT", 2*time.Second),
+		WSCheckInterval:    envDuration("WS_CHECK_INTERVAL", 10*time.Second),
+		WSHandshakeTimeout: envDuration("WS_HANDSHAKE_TIMEOUT", 10*time.Second),
+
+		ManualCheckInterval: envDuration("MANUAL_CHECK_INTERVAL", 30*time.Second),
+		ScrapeCacheTTL:      envDuration("SCRAPE_CACHE_TTL", 15*time.Second),
+
+		IngestToken: envString("INGEST_TOKEN", ""),
+		AdminToken:  envString("ADMIN_TOKEN", ""),
+
+		StoreBackend: envString("STORE_BACKEND", "memory"),
+		DatabaseURL:  envString("DATABASE_URL", ""),
+
+		StoreRetryInterval: envDuration("STORE_RETRY_INTERVAL", 10*time.Second),
+		StoreReplayBuffer:  envInt("STORE_REPLAY_BUFFER", 10000),
+
+		MonitorStaleTicks: envInt("MONITOR_STALE_TICKS", 3),
+
+		ItemLeases: envBool("ITEM_LEASES", false),
+		InstanceID: envString("INSTANCE_ID", defaultInstanceID()),
+
+		RateLimitDefault:    envString("RATE_LIMIT_DEFAULT", "120/m"),
+		RateLimits:          envList("RATE_LIMITS"),
+		RateLimitTrustProxy: envBool("RATE_LIMIT_TRUST_PROXY", false),
+		RateLimitProxyHops:  envInt("RATE_LIMIT_PROXY_HOPS", 1),
+
+		MaxRequestBodyBytes: int64(envInt("MAX_REQUEST_BODY_BYTES", 1<<20)),
+		MaxScrapeBody:       int64(envInt("MAX_SCRAPE_BODY", 10<<20)),
+		RequestReadTimeout:  envDuration("REQUEST_READ_TIMEOUT", 10*time.Second),
+
+		DomainConfigFile: envString("DOMAIN_CONFIG_FILE", ""),
+
+		BreakerFailureThreshold: envInt("BREAKER_FAILURE_THRESHOLD", 5),
+		BreakerCooldown:         envDuration("BREAKER_COOLDOWN", 5*time.Minute),
+
+		ThrottleCooldown:    envDuration("THROTTLE_COOLDOWN", time.Minute),
+		ThrottleMaxCooldown: envDuration("THROTTLE_MAX_COOLDOWN", time.Hour),
+
+		RobotsTxt:         envString("ROBOTS_TXT", "ignore"),
+		RobotsCacheTTL:    envDuration("ROBOTS_CACHE_TTL", 24*time.Hour),
+		DomainConcurrency: envInt("DOMAIN_CONCURRENCY", 0),
+
+		FollowBuyingOptions: envBool("FOLLOW_BUYING_OPTIONS", false),
+
+		ChromeURL: envString("CHROME_URL", ""),
+
+		ScrapeAllowPrivate: envBool("SCRAPE_ALLOW_PRIVATE", false),
+		ScrapeAllowedHosts: envList("SCRAPE_ALLOWED_HOSTS"),
+		ScrapeDeniedHosts:  envList("SCRAPE_DENIED_HOSTS"),
+		HeadlessTimeout:    envDuration("HEADLESS_TIMEOUT", 45*time.Second),
+
+		ScrapeProxies:    envList("SCRAPE_PROXIES"),
+		ProxyMaxFailures: envInt("PROXY_MAX_FAILURES", 3),
+		ProxyCooldown:    envDuration("PROXY_COOLDOWN", 5*time.Minute),
+
+		ScrapeDebug:    envBool("SCRAPE_DEBUG", false),
+		ScrapeDebugDir: envString("SCRAPE_DEBUG_DIR", ""),
+
+		ScraperUserAgent:      envString("SCRAPER_USER_AGENT", scraper.DefaultOptions.UserAgent),
+		ScraperAccept:         envString("SCRAPER_ACCEPT", scraper.DefaultOptions.Accept),
+		ScraperAcceptLanguage: envString("SCRAPER_ACCEPT_LANGUAGE", scraper.DefaultOptions.AcceptLanguage),
+
+		NotifyLocale:        envString("NOTIFY_LOCALE", notify.DefaultLocale),
+		NotifyTitleTemplate: envString("NOTIFY_TITLE_TEMPLATE", ""),
+		NotifyBodyTemplate:  envString("NOTIFY_BODY_TEMPLATE", ""),
+
+		WebhookURL:       envString("WEBHOOK_URL", ""),
+		TelegramBotToken: envString("TELEGRAM_BOT_TOKEN", ""),
+		TelegramChatID:   envString("TELEGRAM_CHAT_ID", ""),
+		SMTPHost:         envString("SMTP_HOST", ""),
+		SMTPPort:         envString("SMTP_PORT", "587"),
+		SMTPUsername:     envString("SMTP_USERNAME", ""),
+		SMTPPassword:     envString("SMTP_PASSWORD", ""),
+		SMTPFrom:         envString("SMTP_FROM", ""),
+		SMTPTo:           envList("SMTP_TO"),
+
+		NotifyTimeout:      envDuration("NOTIFY_TIMEOUT", 10*time.Second),
+		NotifyRetries:      envInt("NOTIFY_RETRIES", 3),
+		NotifyRetryBackoff: envDuration("NOTIFY_RETRY_BACKOFF", time.Second),
+		NotifyMaxRetryWait: envDuration("NOTIFY_MAX_RETRY_WAIT", 30*time.Second),
+	}
+}
+
+// defaultInstanceID is unique per process: the hostname plus the PID
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return host + "-" + strconv.Itoa(os.Getpid())
+}
+
+func envString(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok && strings.TrimSpace(v) != "" {
+		return strings.TrimSpace(v)
+	}
+	return def
+}
+
+func envInt(key string, def int) int {
+	v := envString(key, "")
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Invalid value for %s (%q), using default %d", key, v, def)
+		return def
+	}
+	return n
+}
+
+func envFloat(key string, def float64) float64 {
+	v := envString(key, "")
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("Invalid value for %s (%q), using default %v", key, v, def)
+		return def
+	}
+	return f
+}
+
+func envBool(key string, def bool) bool {
+	v := envString(key, "")
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("Invalid value for %s (%q), using default %v", key, v, def)
+		return def
+	}
+	return b
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	v := envString(key, "")
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Invalid value for %s (%q), using default %s", key, v, def)
+		return def
+	}
+	return d
+}
+
+func envLocation(key string) *time.Location {
+	v := envString(key, "")
+	if v == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(v)
+	if err != nil {
+		log.Printf("Invalid timezone for %s (%q), using local time", key, v)
+		return time.Local
+	}
+	return loc
+}
+
+// envList splits a comma-separated value, dropping empty entries
+func envList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(envString(key, ""), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// envRates parses "USD=83.2,EUR=90.1" into a rate table
+func envRates(key string) map[string]float64 {
+	rates := make(map[string]float64)
+	for _, pair := range strings.Split(envString(key, ""), ",") {
+		code, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || rate <= 0 {
+			log.Printf("Ignoring invalid rate %q in %s", pair, key)
+			continue
+		}
+		rates[strings.ToUpper(strings.TrimSpace(code))] = rate
+	}
+	return rates
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"price-tracker-backend/currency"
+)
+
+// Longest coupon code an item may carry, in characters
+const maxCouponCodeLength = 64
+
+// validateCoupon checks an item's coupon and trims its code
+func validateCoupon(req *TrackingRequest, problems *validationErrors) {
+	if req.AdjustmentPercent < 0 || req.AdjustmentPercent >= 100 {
+		problems.add("adjustmentPercent", ErrCodeInvalidParameter, "adjustmentPercent must be at least 0 and below 100")
+	}
+	if req.AdjustmentAmount.Sign() < 0 {
+		problems.add("adjustmentAmount", ErrCodeInvalidParameter, "adjustmentAmount can't be negative")
+	}
+	req.CouponCode = strings.TrimSpace(req.CouponCode)
+	if n := utf8.RuneCountInString(req.CouponCode); n > maxCouponCodeLength {
+		problems.add("couponCode", ErrCodeInvalidParameter, fmt.Sprintf("couponCode is %d characters long, at most %d are allowed", n, maxCouponCodeLength))
+	}
+}
+
+// couponPrice is the price the item's coupon brings a scraped price down to:
+// AdjustmentPercent off, then AdjustmentAmount off, both in the price's
+// currency. ok is false without a coupon, or when it would leave nothing to
+// pay, which is more likely a misconfigured coupon or a misread price.
+func couponPrice(item TrackingRequest, price currency.Money) (effective currency.Money, ok bool) {
+	if item.AdjustmentPercent <= 0 && item.AdjustmentAmount.Sign() <= 0 {
+		return price, false
+	}
+	effective = price.Mul(1 - item.AdjustmentPercent/100).Sub(item.AdjustmentAmount)
+	if effective.Sign() <= 0 {
+		return price, false
+	}
+	return effective, true
+}
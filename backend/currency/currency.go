@@ -0,0 +1,181 @@
+// backend/currency/currency.go
+package currency
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// symbols maps price string symbols to ISO currency codes, in the order they're
+// looked for: prefixed dollars first, since "CA$" also contains "A$" and "$"
+var symbols = []struct{ symbol, code string }{
+	{"US$", "USD"},
+	{"CA$", "CAD"},
+	{"AU$", "AUD"},
+	{"NZ$", "NZD"},
+	{"HK$", "HKD"},
+	{"A$", "AUD"},
+	{"C$", "CAD"},
+	{"S$", "SGD"},
+	{"₹", "INR"},
+	{"€", "EUR"},
+	{"£", "GBP"},
+	{"¥", "JPY"},
+}
+
+// Currencies written with a bare "$"
+var dollarCurrencies = []string{"USD", "CAD", "AUD", "NZD", "HKD", "SGD"}
+
+// Domain suffixes for sites whose price strings don't carry a symbol (e.g. Amazon's .a-price-whole)
+var domainCurrencies = map[string]string{
+	"amazon.in":     "INR",
+	"amazon.com":    "USD",
+	"amazon.ca":     "CAD",
+	"amazon.com.au": "AUD",
+	"amazon.sg":     "SGD",
+	"amazon.co.uk":  "GBP",
+	"amazon.de":     "EUR",
+	"amazon.fr":     "EUR",
+	"amazon.co.jp":  "JPY",
+	"flipkart.com":  "INR",
+}
+
+// Converter normalizes prices to a single base currency
+type Converter struct {
+	Base   string
+	mu     sync.RWMutex
+	rates  map[string]float64 // 1 unit of key currency = value units of Base
+	apiURL string
+}
+
+func NewConverter(base string, rates map[string]float64, apiURL string) *Converter {
+	c := &Converter{
+		Base:   strings.ToUpper(base),
+		rates:  make(map[string]float64),
+		apiURL: apiURL,
+	}
+	for code, rate := range rates {
+		c.rates[strings.ToUpper(code)] = rate
+	}
+	return c
+}
+
+// Detect guesses the currency of a price from its string, falling back to the
+// URL's domain. A bare "$" is the domain's dollar currency, USD on sites
+// without one, and unknown ("") on a site that prices in something else.
+func Detect(priceString, urlStr string) string {
+	for _, s := range symbols {
+		if strings.Contains(priceString, s.symbol) {
+			return s.code
+		}
+	}
+	domainCode := domainCurrency(urlStr)
+	if strings.Contains(priceString, "$") {
+		switch {
+		case domainCode == "":
+			return "USD"
+		case slices.Contains(dollarCurrencies, domainCode):
+			return domainCode
+		default:
+			return ""
+		}
+	}
+	return domainCode
+}
+
+// domainCurrency is the currency of the site at urlStr, "" if it isn't known.
+// The longest matching domain wins, so amazon.com.au isn't taken for amazon.com.
+func domainCurrency(urlStr string) string {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	code, matched := "", ""
+	for domain, c := range domainCurrencies {
+		if (host == domain || strings.HasSuffix(host, "."+domain)) && len(domain) > len(matched) {
+			code, matched = c, domain
+		}
+	}
+	return code
+}
+
+// Convert returns amount, in its own currency, expressed in the base currency
+// and rounded to its minor unit
+func (c *Converter) Convert(amount Money) (Money, error) {
+	from := strings.ToUpper(amount.Currency)
+	if from == "" || from == c.Base {
+		return amount.In(c.Base), nil
+	}
+	c.mu.RLock()
+	rate, ok := c.rates[from]
+	c.mu.RUnlock()
+	if !ok {
+		return Money{}, fmt.Errorf("no exchange rate for %s to %s", from, c.Base)
+	}
+	return FromFloat(amount.Float()*rate, c.Base), nil
+}
+
+// Refresh loads rates from the configured exchange-rate API. The API is expected to
+// return {"rates": {"USD": 0.012, ...}} quoted as units per 1 unit of the base currency.
+func (c *Converter) Refresh() error {
+	if c.apiURL == "" {
+		return nil
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.Get(c.apiURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch exchange rates: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return fmt.Errorf("bad status from exchange rate API: %s", res.Status)
+	}
+
+	var body struct {
+		Rates map[string]float64 `json:"rates"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return fmt.Errorf("failed to decode exchange rates: %w", err)
+	}
+
+	c.mu.Lock()
+	for code, perBase := range body.Rates {
+		if perBase > 0 {
+			c.rates[strings.ToUpper(code)] = 1 / perBase // Invert to "base units per 1 code"
+		}
+	}
+	c.mu.Unlock()
+	log.Printf("Loaded %d exchange rates from API", len(body.Rates))
+	return nil
+}
+
+// StartRefreshing periodically reloads rates from the API until stop is closed
+func (c *Converter) StartRefreshing(interval time.Duration, stop <-chan struct{}) {
+	if c.apiURL == "" {
+		return
+	}
+	if err := c.Refresh(); err != nil {
+		log.Printf("Exchange rate refresh failed: %v", err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Refresh(); err != nil {
+				log.Printf("Exchange rate refresh failed: %v", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
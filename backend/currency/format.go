@@ -0,0 +1,103 @@
+// backend/currency/format.go
+package currency
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// formatStyle describes how amounts in one currency are written
+type formatStyle struct {
+	symbol       string
+	symbolAfter  bool   // "1.234,56 €" rather than "€1,234.56"
+	decimals     int    // Digits after the decimal separator
+	group        string // Thousands separator
+	decimal      string
+	indianGroups bool // Group as 1,00,000 rather than 100,000
+}
+
+var formatStyles = map[string]formatStyle{
+	"INR": {symbol: "₹", decimals: 2, group: ",", decimal: ".", indianGroups: true},
+	"USD": {symbol: "$", decimals: 2, group: ",", decimal: "."},
+	"EUR": {symbol: "€", symbolAfter: true, decimals: 2, group: ".", decimal: ","},
+	"GBP": {symbol: "£", decimals: 2, group: ",", decimal: "."},
+	"JPY": {symbol: "¥", decimals: 0, group: ",", decimal: "."},
+}
+
+// localeStyle overrides how a locale writes numbers, whatever the currency
+type localeStyle struct {
+	group       string
+	decimal     string
+	symbolAfter bool
+}
+
+var localeStyles = map[string]localeStyle{
+	"de": {group: ".", decimal: ",", symbolAfter: true},
+}
+
+// Format renders amount the way the currency is conventionally written, e.g.
+// "₹60,100.00", "$1,299.99" or "1.299,99 €". Unknown currencies are written as
+// "1,299.99 XYZ", and an empty code gives just the grouped number.
+func Format(amount float64, code string) string {
+	return FormatLocale(amount, code, "")
+}
+
+// FormatLocale is Format using a locale's separators and symbol placement, e.g.
+// "60.100,00 ₹" for "de". Locales without their own style write amounts the way
+// the currency is conventionally written.
+func FormatLocale(amount float64, code, locale string) string {
+	code = strings.ToUpper(code)
+	style, ok := formatStyles[code]
+	if !ok {
+		style = formatStyle{symbol: code, symbolAfter: true, decimals: 2, group: ",", decimal: "."}
+	}
+	if ls, ok := localeStyles[strings.ToLower(locale)]; ok {
+		style.group, style.decimal, style.symbolAfter = ls.group, ls.decimal, ls.symbolAfter
+		style.indianGroups = false
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	scale := math.Pow(10, float64(style.decimals))
+	amount = math.Round(amount*scale) / scale
+
+	digits := strconv.FormatFloat(amount, 'f', style.decimals, 64)
+	whole, frac, _ := strings.Cut(digits, ".")
+	number := groupDigits(whole, style.group, style.indianGroups)
+	if frac != "" {
+		number += style.decimal + frac
+	}
+
+	switch {
+	case style.symbol == "":
+		return sign + number
+	case style.symbolAfter:
+		return sign + number + " " + style.symbol
+	default:
+		return sign + style.symbol + number
+	}
+}
+
+// groupDigits inserts separators into a run of digits: every three digits, or
+// for Indian grouping the last three and then every two
+func groupDigits(digits, sep string, indian bool) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+	head, tail := digits[:len(digits)-3], digits[len(digits)-3:]
+	size := 3
+	if indian {
+		size = 2
+	}
+	var parts []string
+	for len(head) > size {
+		parts = append([]string{head[len(head)-size:]}, parts...)
+		head = head[:len(head)-size]
+	}
+	parts = append([]string{head}, parts...)
+	return strings.Join(append(parts, tail), sep)
+}
@@ -0,0 +1,247 @@
+package currency
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in a currency's minor units (paise, cents), so amounts
+// compare and subtract exactly. It's written to JSON as a plain decimal number
+// such as 4999.5, so API responses and stored documents look as they did when
+// prices were floats, and read back from one without its currency.
+type Money struct {
+	Minor    int64
+	Currency string // Decides how many minor units make one unit; empty means 100
+}
+
+// MinorDigits is how many decimals the currency's minor unit has, e.g. 2 for
+// INR and 0 for JPY
+func MinorDigits(code string) int {
+	if style, ok := formatStyles[strings.ToUpper(code)]; ok {
+		return style.decimals
+	}
+	return 2
+}
+
+// FromFloat rounds amount to the nearest minor unit
+func FromFloat(amount float64, code string) Money {
+	scale := math.Pow10(MinorDigits(code))
+	return Money{Minor: int64(math.Round(amount * scale)), Currency: code}
+}
+
+// ParseMoney reads a plain decimal such as "1299.50" or "-3" without going
+// through a float, rounding half away from zero beyond the currency's minor unit
+func ParseMoney(s, code string) (Money, error) {
+	digits := MinorDigits(code)
+	str := strings.TrimSpace(s)
+	negative := strings.HasPrefix(str, "-")
+	str = strings.TrimPrefix(strings.TrimPrefix(str, "-"), "+")
+
+	whole, frac, _ := strings.Cut(str, ".")
+	if whole == "" && frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	roundUp := len(frac) > digits && frac[digits] >= '5'
+	if len(frac) > digits {
+		frac = frac[:digits]
+	}
+	frac += strings.Repeat("0", digits-len(frac))
+
+	minor, err := strconv.ParseInt(whole+frac, 10, 64)
+	if whole+frac == "" {
+		minor, err = 0, nil
+	}
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q: %w", s, err)
+	}
+	if roundUp {
+		minor++
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{Minor: minor, Currency: code}, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Float returns the amount in whole units
+func (m Money) Float() float64 {
+	return float64(m.Minor) / math.Pow10(MinorDigits(m.Currency))
+}
+
+// String formats the amount like Format, e.g. "₹4,999.00"
+func (m Money) String() string {
+	return Format(m.Float(), m.Currency)
+}
+
+// Cmp returns -1, 0 or +1 as m is less than, equal to or greater than other.
+// Both must be in the same currency, or one of them without a currency, e.g.
+// read from JSON.
+func (m Money) Cmp(other Money) int {
+	a, b, _ := align(m, other)
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func (m Money) Add(other Money) Money {
+	a, b, code := align(m, other)
+	return Money{Minor: a + b, Currency: code}
+}
+
+func (m Money) Sub(other Money) Money {
+	a, b, code := align(m, other)
+	return Money{Minor: a - b, Currency: code}
+}
+
+// align returns both amounts in the finer of their minor units, and the
+// currency that has them
+func align(m, other Money) (int64, int64, string) {
+	code := cmpOr(m.Currency, other.Currency)
+	md, od := MinorDigits(m.Currency), MinorDigits(other.Currency)
+	switch {
+	case md < od:
+		return m.Minor * pow10(od-md), other.Minor, other.Currency
+	case md > od:
+		return m.Minor, other.Minor * pow10(md-od), m.Currency
+	}
+	return m.Minor, other.Minor, code
+}
+
+func cmpOr(a, b string) string {
+	if a != "" {
+		return a
+	}
+	return b
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for range n {
+		p *= 10
+	}
+	return p
+}
+
+// Min returns the smaller of a and b
+func Min(a, b Money) Money {
+	if a.Cmp(b) <= 0 {
+		return a
+	}
+	return b
+}
+
+// Max returns the larger of a and b
+func Max(a, b Money) Money {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+// PercentChange is how many percent to is above from, negative when it's below
+func PercentChange(from, to Money) float64 {
+	return to.Sub(from).Float() / from.Float() * 100
+}
+
+// Mul returns m times factor, rounded to the minor unit, e.g. for a discount
+// of a percentage
+func (m Money) Mul(factor float64) Money {
+	return Money{Minor: int64(math.Round(float64(m.Minor) * factor)), Currency: m.Currency}
+}
+
+// Sign returns -1, 0 or +1 as m is negative, zero or positive
+func (m Money) Sign() int {
+	switch {
+	case m.Minor < 0:
+		return -1
+	case m.Minor > 0:
+		return 1
+	}
+	return 0
+}
+
+// IsZero reports whether the amount is zero, so `json:",omitzero"` leaves
+// unset prices out like omitempty did for floats
+func (m Money) IsZero() bool {
+	return m.Minor == 0
+}
+
+// In returns the amount marked as being in code, e.g. a price read from JSON
+// in the base currency, rounding if code has fewer minor digits
+func (m Money) In(code string) Money {
+	from, to := MinorDigits(m.Currency), MinorDigits(code)
+	switch {
+	case from < to:
+		return Money{Minor: m.Minor * pow10(to-from), Currency: code}
+	case from > to:
+		return FromFloat(m.Float(), code)
+	}
+	return Money{Minor: m.Minor, Currency: code}
+}
+
+// Decimal writes the amount as a plain decimal without trailing zeros, e.g.
+// "4999.5" or "-3"
+func (m Money) Decimal() string {
+	digits := MinorDigits(m.Currency)
+	minor := m.Minor
+	sign := ""
+	if minor < 0 {
+		sign, minor = "-", -minor
+	}
+	str := strconv.FormatInt(minor, 10)
+	if digits == 0 {
+		return sign + str
+	}
+	if len(str) <= digits {
+		str = strings.Repeat("0", digits-len(str)+1) + str
+	}
+	whole, frac := str[:len(str)-digits], strings.TrimRight(str[len(str)-digits:], "0")
+	if frac == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + frac
+}
+
+// MarshalJSON writes the amount as a JSON number, see Decimal
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.Decimal()), nil
+}
+
+// UnmarshalJSON reads a JSON number, or null for zero. The currency isn't part
+// of it, so the amount is kept in hundredths unless m already has a currency.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	str := string(data)
+	if str == "null" {
+		*m = Money{Currency: m.Currency}
+		return nil
+	}
+	if strings.ContainsAny(str, "eE") {
+		amount, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return fmt.Errorf("invalid amount %s", str)
+		}
+		*m = FromFloat(amount, m.Currency)
+		return nil
+	}
+	parsed, err := ParseMoney(str, m.Currency)
+	if err != nil || strings.HasPrefix(str, "+") {
+		return fmt.Errorf("invalid amount %s", str)
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"cmp"
+	"math"
+
+	"price-tracker-backend/currency"
+)
+
+// Deal ratings are only given once an item has this many history points
+const minDealSamples = 5
+
+// dealRating scores a price against an item's history: DealScore is 0 at the
+// all-time high and 100 at the all-time low, and a price within
+// DEAL_THRESHOLD_PERCENT of the all-time low is a good deal. ok is false when
+// there's too little history to tell.
+func dealRating(history []PricePoint, price currency.Money) (score float64, goodDeal bool, ok bool) {
+	if len(history) < minDealSamples || price.Sign() <= 0 {
+		return 0, false, false
+	}
+	var low, high currency.Money
+	for _, p := range history {
+		if p.ConvertedPrice.Sign() <= 0 {
+			continue
+		}
+		if low.IsZero() {
+			low, high = p.ConvertedPrice, p.ConvertedPrice
+		}
+		low = currency.Min(low, p.ConvertedPrice)
+		high = currency.Max(high, p.ConvertedPrice)
+	}
+	if low.IsZero() {
+		return 0, false, false
+	}
+
+	score = 100
+	if high.Cmp(low) > 0 {
+		score = math.Round(math.Max(0, math.Min(1, high.Sub(price).Float()/high.Sub(low).Float()))*1000) / 10
+	}
+	return score, price.Cmp(low.Mul(1+cfg.DealThresholdPercent/100)) <= 0, true
+}
+
+// withDealRating fills in an item's deal fields from its stored history
+func withDealRating(item TrackingRequest) TrackingRequest {
+	item.DealScore, item.IsGoodDeal = nil, false
+	history, _, err := store.History(item.ID)
+	if err != nil || len(history) == 0 {
+		return item
+	}
+	// Rated against the points comparable with the latest one
+	latest := history[len(history)-1]
+	history = comparableHistory(history, cmp.Or(converter.Base, comparisonCurrency(latest)))
+	price := item.LastPrice
+	if price.IsZero() {
+		price = latest.ConvertedPrice
+	}
+	if score, good, ok := dealRating(history, price); ok {
+		item.DealScore, item.IsGoodDeal = &score, good
+	}
+	return item
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Longest HTML snippet logged when SCRAPE_DEBUG_DIR isn't set
+const debugSnippetBytes = 2048
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// dumpScrapedHTML records a page that yielded no price so the failure can be
+// diagnosed. It does nothing unless SCRAPE_DEBUG is enabled.
+func dumpScrapedHTML(ctx context.Context, url string, status int, body []byte, cause error) {
+	if !cfg.ScrapeDebug {
+		return
+	}
+	header := fmt.Sprintf("URL: %s\nStatus: %d\nError: %v\nPrice selectors: %s\nStock selectors: %s\n", url, status, cause, priceSelectors, stockSelectors)
+
+	if cfg.ScrapeDebugDir == "" {
+		snippet := body
+		if len(snippet) > debugSnippetBytes {
+			snippet = snippet[:debugSnippetBytes]
+		}
+		logf(ctx, "Scrape debug dump (%d bytes of HTML, showing %d)\n%s\n%s", len(body), len(snippet), header, snippet)
+		return
+	}
+
+	if err := os.MkdirAll(cfg.ScrapeDebugDir, 0o755); err != nil {
+		logf(ctx, "Failed to create scrape debug dir: %v", err)
+		return
+	}
+	name := time.Now().UTC().Format("20060102T150405.000") + "-" + unsafeFileChars.ReplaceAllString(url, "_")
+	if len(name) > 200 {
+		name = name[:200]
+	}
+	path := filepath.Join(cfg.ScrapeDebugDir, name+".html")
+	content := append([]byte("<!--\n"+header+"-->\n"), body...)
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		logf(ctx, "Failed to write scrape debug dump: %v", err)
+		return
+	}
+	logf(ctx, "Wrote scrape debug dump for %s to %s", url, path)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"price-tracker-backend/currency"
+)
+
+// alertSignature identifies alerts that would read the same to the user. Alerts
+// are about the product page rather than the item ID, so an immediate alert
+// from check-price and the monitor's alert for the same drop match.
+type alertSignature struct {
+	Subject  string // URL, or the item ID for alerts without one
+	Type     string
+	Price    int64          // Price bucket, see priceBucket
+	Target   currency.Money // In hundredths whatever the currency, so equal targets match
+	Channels string
+}
+
+var (
+	sentAlerts   = make(map[alertSignature]time.Time) // Alerts delivered within ALERT_DEDUPE_WINDOW
+	sentAlertsMu sync.Mutex
+
+	// Alerts let through and suppressed as duplicates since startup
+	alertsDelivered, alertsSuppressed int64
+
+	// Immediate check-price alerts started within IMMEDIATE_ALERT_WINDOW, by URL
+	// and target, and the ones skipped since startup
+	immediateAlerts        = make(map[immediateAlertKey]time.Time)
+	immediateAlertsSkipped int64
+)
+
+type immediateAlertKey struct {
+	URL    string
+	Target currency.Money // As in alertSignature
+}
+
+// priceBucket groups prices within ALERT_DEDUPE_PRICE_BUCKET percent of each
+// other, so an alert a few paise off the last one still counts as a repeat.
+// Without a bucket size prices are compared to the cent.
+func priceBucket(price currency.Money) int64 {
+	if cfg.AlertDedupePriceBucket <= 0 || price.Sign() <= 0 {
+		return price.In("").Minor
+	}
+	return int64(math.Floor(math.Log(price.Float()) / math.Log1p(cfg.AlertDedupePriceBucket/100)))
+}
+
+func signatureOf(alert PriceAlert) alertSignature {
+	channels := slices.Clone(alert.Channels)
+	slices.Sort(channels)
+	return alertSignature{
+		Subject:  cmp.Or(alert.URL, alert.ID),
+		Type:     alert.Type,
+		Price:    priceBucket(alert.ConvertedPrice),
+		Target:   alert.TargetPrice.In(""),
+		Channels: strings.Join(channels, ","),
+	}
+}
+
+// isDuplicateAlert reports whether an identical alert was already sent within
+// ALERT_DEDUPE_WINDOW, and otherwise records this one as sent. Every alert path
+// goes through it, under one lock, so two goroutines alerting the same drop at
+// once send it only once. A price in another bucket always gets through.
+func isDuplicateAlert(alert PriceAlert, now time.Time) bool {
+	sentAlertsMu.Lock()
+	defer sentAlertsMu.Unlock()
+	if cfg.AlertDedupeWindow <= 0 {
+		alertsDelivered++
+		return false
+	}
+	sig := signatureOf(alert)
+	for s, sent := range sentAlerts {
+		if now.Sub(sent) > cfg.AlertDedupeWindow {
+			delete(sentAlerts, s)
+		}
+	}
+	if _, ok := sentAlerts[sig]; ok {
+		alertsSuppressed++
+		return true
+	}
+	sentAlerts[sig] = now
+	alertsDelivered++
+	return false
+}
+
+// immediateAlertDue reports whether a check-price of url below target may
+// alert, and records that it did. Repeated checks of the same URL and target
+// within IMMEDIATE_ALERT_WINDOW, e.g. from a check button pressed again and
+// again, alert only once, whatever the price; the monitor isn't affected.
+func immediateAlertDue(url string, target currency.Money, now time.Time) bool {
+	sentAlertsMu.Lock()
+	defer sentAlertsMu.Unlock()
+	if cfg.ImmediateAlertWindow <= 0 {
+		return true
+	}
+	for k, at := range immediateAlerts {
+		if now.Sub(at) > cfg.ImmediateAlertWindow {
+			delete(immediateAlerts, k)
+		}
+	}
+	key := immediateAlertKey{URL: url, Target: target.In("")}
+	if _, ok := immediateAlerts[key]; ok {
+		immediateAlertsSkipped++
+		return false
+	}
+	immediateAlerts[key] = now
+	return true
+}
+
+// dedupeStats reports the alert counters for /api/stats
+func dedupeStats() map[string]interface{} {
+	sentAlertsMu.Lock()
+	defer sentAlertsMu.Unlock()
+	return map[string]interface{}{
+		"delivered":  alertsDelivered,
+		"duplicates": alertsSuppressed,
+		"remembered": len(sentAlerts),
+
+		"immediateSkipped": immediateAlertsSkipped,
+	}
+}
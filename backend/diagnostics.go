@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+	"github.com/gorilla/mux"
+
+	"price-tracker-backend/currency"
+	"price-tracker-backend/scraper"
+)
+
+// Places on a product page a price can be read from, as reported by the diagnostics
+const (
+	ExtractSelector = "selector" // One of the CSS selectors the html scrape method uses
+	ExtractItemprop = "itemprop" // schema.org microdata
+	ExtractMeta     = "meta"     // Open Graph product meta tags
+	ExtractJSONLD   = "jsonld"   // schema.org JSON-LD offers
+)
+
+// ExtractionStep is one attempt to read the price from a page
+type ExtractionStep struct {
+	Method  string          `json:"method"`
+	Query   string          `json:"query"`           // Selector (with @attribute if one is read) or JSON path tried
+	Used    bool            `json:"usedByScraper"`   // Whether the html scrape method reads the price this way
+	Matched bool            `json:"matched"`         // Whether the page had anything there
+	Raw     string          `json:"raw,omitempty"`   // The text found
+	Value   *currency.Money `json:"value,omitempty"` // The text parsed as a price
+	Error   string          `json:"error,omitempty"` // Why the text didn't parse
+}
+
+// newExtractionStep records what a query found, parsing it when it found anything
+func newExtractionStep(method, query string, used bool, raw string, format scraper.NumberFormat) ExtractionStep {
+	step := ExtractionStep{Method: method, Query: query, Used: used, Raw: raw, Matched: raw != ""}
+	if raw == "" {
+		return step
+	}
+	if price, err := parseScrapedPrice(raw, format); err != nil {
+		step.Error = err.Error()
+	} else {
+		step.Value = &price
+	}
+	return step
+}
+
+// traceExtraction tries every way of reading the price from a page's <html>
+// element: the scraper's own selectors first, then structured data it doesn't
+// use yet, which makes for a good selector when a site changes
+func traceExtraction(root *goquery.Selection, dc *DomainConfig) []ExtractionStep {
+	var steps []ExtractionStep
+	// The site's separators apply to prices it displays; structured data is
+	// written for machines, with "." decimals
+	var format scraper.NumberFormat
+	if dc != nil {
+		format = dc.NumberFormat
+	}
+	if dc != nil && dc.PriceSelector != nil {
+		query := dc.PriceSelector.Selector
+		if dc.PriceSelector.Attribute != "" {
+			query += " @" + dc.PriceSelector.Attribute
+		}
+		if pick := dc.PriceSelector.Pick; pick != "" {
+			query += " (pick " + pick + ")"
+		}
+		steps = append(steps, newExtractionStep(ExtractSelector, query, true, dc.PriceSelector.Extract(root, format), format))
+	}
+	for _, selector := range strings.Split(priceSelectors, ",") {
+		selector = strings.TrimSpace(selector)
+		steps = append(steps, newExtractionStep(ExtractSelector, selector, true, firstText(root.Find(selector)), format))
+	}
+	for _, query := range []string{"[itemprop='price']", "[itemprop='lowPrice']"} {
+		steps = append(steps, newExtractionStep(ExtractItemprop, query, false, firstText(root.Find(query)), scraper.NumberFormat{}))
+	}
+	for _, query := range []string{"meta[property='product:price:amount']", "meta[property='og:price:amount']"} {
+		steps = append(steps, newExtractionStep(ExtractMeta, query, false, firstText(root.Find(query)), scraper.NumberFormat{}))
+	}
+	steps = append(steps, newExtractionStep(ExtractJSONLD, "offers.price", false, jsonLDPrice(root), scraper.NumberFormat{}))
+	return steps
+}
+
+// firstText returns the first non-empty text, or content attribute for meta
+// tags, among the matched elements
+func firstText(s *goquery.Selection) string {
+	var text string
+	s.EachWithBreak(func(_ int, el *goquery.Selection) bool {
+		text = strings.TrimSpace(el.Text())
+		if text == "" {
+			text = strings.TrimSpace(el.AttrOr("content", ""))
+		}
+		return text == ""
+	})
+	return text
+}
+
+// jsonLDPrice returns the first offer price in the page's JSON-LD scripts
+func jsonLDPrice(root *goquery.Selection) string {
+	var price string
+	root.Find("script[type='application/ld+json']").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		var data interface{}
+		if json.Unmarshal([]byte(s.Text()), &data) == nil {
+			price = findOfferPrice(data)
+		}
+		return price == ""
+	})
+	return price
+}
+
+// findOfferPrice searches JSON-LD, including @graph lists and nested products,
+// for an offer's price or lowPrice
+func findOfferPrice(data interface{}) string {
+	switch v := data.(type) {
+	case []interface{}:
+		for _, elem := range v {
+			if price := findOfferPrice(elem); price != "" {
+				return price
+			}
+		}
+	case map[string]interface{}:
+		if offers, ok := v["offers"]; ok {
+			for _, offer := range asList(offers) {
+				if o, ok := offer.(map[string]interface{}); ok {
+					for _, key := range []string{"price", "lowPrice"} {
+						switch p := o[key].(type) {
+						case float64:
+							return strconv.FormatFloat(p, 'f', -1, 64)
+						case string:
+							if p = strings.TrimSpace(p); p != "" {
+								return p
+							}
+						}
+					}
+				}
+			}
+		}
+		for _, value := range v {
+			if price := findOfferPrice(value); price != "" {
+				return price
+			}
+		}
+	}
+	return ""
+}
+
+func asList(v interface{}) []interface{} {
+	if list, ok := v.([]interface{}); ok {
+		return list
+	}
+	return []interface{}{v}
+}
+
+// fetchTrace fetches a page's static HTML like the html scrape method and
+// traces the price extraction on it
+func fetchTrace(ctx context.Context, url string) (int, []ExtractionStep, error) {
+	c := newCollector(ctx, url)
+	var status int
+	var steps []ExtractionStep
+	c.OnResponse(func(r *colly.Response) {
+		status = r.StatusCode
+	})
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		steps = traceExtraction(e.DOM, domainConfigFor(url))
+	})
+	err := c.Visit(url)
+	return status, steps, err
+}
+
+// diagnosticsHandler re-scrapes a tracked item and reports how its price was
+// found: the result of the domain's scrape methods, and a trace of every way
+// of reading the price from the static HTML. It's meant for fixing selectors
+// after a site changes, so it fetches the page twice.
+func diagnosticsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+	item, err := store.GetItem(id)
+	if errors.Is(err, errItemNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("No tracked item with ID %q", id))
+		return
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	ctx := withScraperOptions(r.Context(), item.ScraperOptions)
+	logf(ctx, "Diagnosing price extraction for %s", id)
+
+	dc := domainConfigFor(item.URL)
+	scrape := map[string]interface{}{"methods": scrapeMethods(ctx, dc)}
+	result, err := scrapePrice(ctx, item.URL)
+	if err != nil {
+		scrape["error"] = err.Error()
+	} else {
+		scrape["method"] = result.Method
+		scrape["currentPrice"] = result.Price
+		scrape["priceString"] = result.PriceString
+		scrape["currency"] = result.Currency
+		scrape["inStock"] = result.InStock
+	}
+
+	trace := map[string]interface{}{}
+	done, err := politeScrape(ctx, item.URL)
+	if err == nil {
+		var status int
+		var steps []ExtractionStep
+		status, steps, err = fetchTrace(ctx, item.URL)
+		done()
+		trace["status"], trace["steps"] = status, steps
+	}
+	if err != nil {
+		trace["error"] = err.Error()
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"url":     item.URL,
+		"scrape":  scrape,
+		"trace":   trace,
+	})
+}
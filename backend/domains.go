@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/cascadia"
+
+	"price-tracker-backend/currency"
+	"price-tracker-backend/scraper"
+)
+
+// DomainConfig customizes how prices are fetched for one site. Configs are loaded
+// from the JSON array in DOMAIN_CONFIG_FILE.
+type DomainConfig struct {
+	Domain string `json:"domain"` // Matches the host and its subdomains, e.g. "example.com"
+
+	// Scraped prices outside this range are treated as parse errors; 0 leaves a bound open
+	MinPlausiblePrice currency.Money `json:"minPlausiblePrice,omitzero"`
+	MaxPlausiblePrice currency.Money `json:"maxPlausiblePrice,omitzero"`
+
+	// Adjustments applied to scraped prices before they're compared, see PriceTransform
+	Transforms []PriceTransform `json:"transforms,omitempty"`
+
+	// Politeness: at most Concurrency scrapes of the site at once (DOMAIN_CONCURRENCY
+	// if 0), started at least CrawlDelay (e.g. "5s") apart. CrawlDelay overrides
+	// the robots.txt Crawl-delay.
+	Concurrency int    `json:"concurrency,omitempty"`
+	CrawlDelay  string `json:"crawlDelay,omitempty"`
+
+	// HTTPVersion forces scrape requests to "1.1" or "2", for sites that treat
+	// Go's default protocol negotiation differently; empty negotiates as usual
+	HTTPVersion string `json:"httpVersion,omitempty"`
+
+	// Separators the site writes prices with, e.g. "decimal": "," and "group":
+	// "." for "1.234,56"; guessed when unset
+	scraper.NumberFormat
+
+	// Referer sent with scrape requests; defaults to the site's own origin
+	Referer string `json:"referer,omitempty"`
+
+	// PriceSelector reads the price from the site's product pages, e.g.
+	// {"selector": "#price", "attribute": "data-price"}, before the built-in
+	// selectors are tried
+	PriceSelector *scraper.PriceSelectorConfig `json:"priceSelector,omitempty"`
+
+	// Auth logs scrape requests to the site in, see ScrapeAuth
+	Auth *ScrapeAuth `json:"auth,omitempty"`
+
+	// JSON price endpoints. When PricePath is set the page isn't scraped as HTML;
+	// instead Endpoint is requested with Method and BodyTemplate and the price is
+	// read from the JSON response.
+	Method       string            `json:"method,omitempty"`       // Defaults to GET, or POST when a body is set
+	Endpoint     string            `json:"endpoint,omitempty"`     // Template for the request URL, defaults to the item URL
+	BodyTemplate string            `json:"bodyTemplate,omitempty"` // Template for the request body
+	Headers      map[string]string `json:"headers,omitempty"`
+	PricePath    string            `json:"pricePath,omitempty"` // JSON path to the price, e.g. "data.product.price.value" or "offers[0].price"
+
+	// EndpointPattern is a regular expression that finds the JSON endpoint in the
+	// product page's HTML, for sites that embed their product API URL. It takes
+	// the place of Endpoint; the first capture group is the URL if there is one.
+	EndpointPattern string         `json:"endpointPattern,omitempty"`
+	endpointRegexp  *regexp.Regexp // Compiled EndpointPattern
+
+	// Methods is the fallback chain of scrape methods, e.g. ["html", "json",
+	// "headless"], tried in order until one finds a plausible price. The default
+	// is "json" when PricePath is set and "html" otherwise.
+	Methods []string `json:"methods,omitempty"`
+	// WaitFor is a selector the headless method waits for before reading the
+	// page, e.g. the price element; the body by default
+	WaitFor string `json:"waitFor,omitempty"`
+}
+
+var domainConfigs = loadDomainConfigs(cfg.DomainConfigFile)
+
+func loadDomainConfigs(path string) []DomainConfig {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Failed to read domain config %s: %v", path, err)
+		return nil
+	}
+	var configs []DomainConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		log.Printf("Failed to parse domain config %s: %v", path, err)
+		return nil
+	}
+	for i := range configs {
+		configs[i].Domain = strings.TrimPrefix(strings.ToLower(configs[i].Domain), "www.")
+		if d := configs[i].CrawlDelay; d != "" {
+			if _, err := time.ParseDuration(d); err != nil {
+				log.Printf("Ignoring invalid crawlDelay %q for %s", d, configs[i].Domain)
+				configs[i].CrawlDelay = ""
+			}
+		}
+		if v := configs[i].HTTPVersion; v != "" {
+			if _, err := newTransport(v); err != nil {
+				log.Printf("Ignoring httpVersion for %s: %v", configs[i].Domain, err)
+				configs[i].HTTPVersion = ""
+			}
+		}
+		if err := configs[i].NumberFormat.Validate(); err != nil {
+			log.Printf("Ignoring price separators for %s: %v", configs[i].Domain, err)
+			configs[i].NumberFormat = scraper.NumberFormat{}
+		}
+		if auth := configs[i].Auth; auth != nil {
+			if err := auth.resolve(); err != nil {
+				log.Printf("Ignoring auth for %s: %v", configs[i].Domain, err)
+				configs[i].Auth = nil
+			}
+		}
+		if sel := configs[i].PriceSelector; sel != nil {
+			if _, err := cascadia.Compile(sel.Selector); err != nil {
+				log.Printf("Ignoring invalid priceSelector for %s: %v", configs[i].Domain, err)
+				configs[i].PriceSelector = nil
+			} else if err := sel.Validate(); err != nil {
+				log.Printf("Ignoring priceSelector for %s: %v", configs[i].Domain, err)
+				configs[i].PriceSelector = nil
+			}
+		}
+		if err := validateMethods(configs[i]); err != nil {
+			log.Printf("Ignoring scrape methods for %s: %v", configs[i].Domain, err)
+			configs[i].Methods = nil
+		}
+		if err := validateTransforms(configs[i].Transforms); err != nil {
+			log.Printf("Ignoring price transforms for %s: %v", configs[i].Domain, err)
+			configs[i].Transforms = nil
+		}
+		if configs[i].EndpointPattern != "" {
+			re, err := regexp.Compile(configs[i].EndpointPattern)
+			if err != nil {
+				log.Printf("Ignoring invalid endpointPattern for %s: %v", configs[i].Domain, err)
+				continue
+			}
+			configs[i].endpointRegexp = re
+		}
+	}
+	log.Printf("Loaded %d domain configs from %s", len(configs), path)
+	return configs
+}
+
+// priceFormatFor returns the separators prices on a URL's site are written with,
+// the zero NumberFormat to guess them
+func priceFormatFor(rawURL string) scraper.NumberFormat {
+	if dc := domainConfigFor(rawURL); dc != nil {
+		return dc.NumberFormat
+	}
+	return scraper.NumberFormat{}
+}
+
+// domainConfigFor returns the config for a URL's host, or nil if none matches
+func domainConfigFor(rawURL string) *DomainConfig {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	for i := range domainConfigs {
+		d := domainConfigs[i].Domain
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return &domainConfigs[i]
+		}
+	}
+	return nil
+}
+
+// templateData is what endpoint and body templates can refer to, e.g. {{.Query.Get "sku"}}
+type templateData struct {
+	URL   string
+	Host  string
+	Path  string
+	Query url.Values
+}
+
+func newTemplateData(rawURL string) (templateData, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return templateData{}, fmt.Errorf("invalid URL: %w", err)
+	}
+	return templateData{URL: rawURL, Host: u.Host, Path: u.Path, Query: u.Query()}, nil
+}
+
+// refererFor returns the Referer header to send when requesting a URL: the
+// domain's configured one, or the URL's origin
+func refererFor(u *url.URL) string {
+	if dc := domainConfigFor(u.String()); dc != nil && dc.Referer != "" {
+		return dc.Referer
+	}
+	return u.Scheme + "://" + u.Host + "/"
+}
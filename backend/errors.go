@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Machine-readable error codes returned in the "error" envelope
+const (
+	ErrCodeInvalidBody        = "INVALID_BODY"
+	ErrCodeBodyTooLarge       = "BODY_TOO_LARGE"
+	ErrCodeUnknownField       = "UNKNOWN_FIELD"
+	ErrCodeInvalidURL         = "INVALID_URL"
+	ErrCodeBlockedURL         = "BLOCKED_URL"
+	ErrCodeInvalidTargetPrice = "INVALID_TARGET_PRICE"
+	ErrCodeInvalidID          = "INVALID_ID"
+	ErrCodeInvalidParameter   = "INVALID_PARAMETER"
+	ErrCodeValidationFailed   = "VALIDATION_FAILED"
+	ErrCodeLimitExceeded      = "LIMIT_EXCEEDED"
+	ErrCodeRateLimited        = "RATE_LIMITED"
+	ErrCodeCheckInProgress    = "CHECK_IN_PROGRESS"
+	ErrCodeScrapeFailed       = "SCRAPE_FAILED"
+	ErrCodePriceNotFound      = "PRICE_NOT_FOUND"
+	ErrCodeConversionFailed   = "CONVERSION_FAILED"
+	ErrCodeNotFound           = "NOT_FOUND"
+	ErrCodeUnauthorized       = "UNAUTHORIZED"
+	ErrCodeForbidden          = "FORBIDDEN"
+	ErrCodeAlreadyTracked     = "ALREADY_TRACKED"
+	ErrCodeInternal           = "INTERNAL"
+	ErrCodeStoreUnavailable   = "STORE_UNAVAILABLE"
+)
+
+// APIError is the body of every error response: {"error": {"code": ..., "message": ...}}.
+// Validation failures also list every offending field.
+type APIError struct {
+	Status  int          `json:"-"`
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError describes one invalid request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// validationErrors collects field problems so a request reports all of them at once
+type validationErrors []FieldError
+
+func (v *validationErrors) add(field, code, message string) {
+	*v = append(*v, FieldError{Field: field, Code: code, Message: message})
+}
+
+// err returns nil when nothing was added. A single problem keeps its own code so
+// existing clients matching on it keep working.
+func (v validationErrors) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	code := ErrCodeValidationFailed
+	if len(v) == 1 {
+		code = v[0].Code
+	}
+	messages := make([]string, len(v))
+	for i, f := range v {
+		messages[i] = f.Field + ": " + f.Message
+	}
+	return &APIError{Status: http.StatusBadRequest, Code: code, Message: strings.Join(messages, "; "), Fields: v}
+}
+
+func newAPIError(status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// writeAPIError sends err as an error envelope. Errors that aren't an *APIError
+// are reported as internal errors.
+func writeAPIError(w http.ResponseWriter, err error) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		apiErr = newAPIError(http.StatusInternalServerError, ErrCodeInternal, err.Error())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": apiErr})
+}
+
+// writeError sends a JSON error envelope with the given HTTP status
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": APIError{Code: code, Message: message},
+	})
+}
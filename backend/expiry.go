@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// resolveExpiry turns MaxAgeDays into ExpiresAt and validates the result
+func resolveExpiry(item *TrackingRequest, now time.Time) error {
+	if item.MaxAgeDays < 0 {
+		return fmt.Errorf("maxAgeDays cannot be negative")
+	}
+	if item.ExpiresAt == "" {
+		if item.MaxAgeDays > 0 {
+			item.ExpiresAt = now.AddDate(0, 0, item.MaxAgeDays).Format(time.RFC3339)
+		}
+		return nil
+	}
+	expiresAt, err := time.Parse(time.RFC3339, item.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("invalid expiresAt %q, expected an RFC 3339 time such as 2025-01-31T00:00:00Z", item.ExpiresAt)
+	}
+	if !expiresAt.After(now) {
+		return fmt.Errorf("expiresAt must be in the future")
+	}
+	return nil
+}
+
+// isExpired reports whether an item's tracking period has ended
+func isExpired(item TrackingRequest, now time.Time) bool {
+	if item.ExpiresAt == "" {
+		return false
+	}
+	expiresAt, err := time.Parse(time.RFC3339, item.ExpiresAt)
+	return err == nil && !now.Before(expiresAt)
+}
+
+// expireItem untracks an expired item and tells the user about it
+func expireItem(item TrackingRequest) {
+	deleted, err := store.DeleteItem(item.ID)
+	if err != nil {
+		log.Printf("Failed to remove expired item %s: %v", item.ID, err)
+		return
+	}
+	if !deleted {
+		return // Another instance or request got there first
+	}
+	log.Printf("Tracking for item %s expired at %s", item.ID, item.ExpiresAt)
+	notifyStopped(context.Background(), item, StopExpired)
+	deliverAlert(context.Background(), PriceAlert{
+		ID:             item.ID,
+		URL:            item.URL,
+		Locale:         item.Locale,
+		Title:          item.Title,
+		ImageURL:       item.ImageURL,
+		ConvertedPrice: item.LastPrice,
+		BaseCurrency:   converter.Base,
+		Type:           AlertExpired,
+		Timestamp:      time.Now().Format(time.RFC3339),
+	})
+}
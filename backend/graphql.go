@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/graphql-go/graphql"
+
+	"price-tracker-backend/currency"
+)
+
+// moneyField is a Float field backed by a currency.Money, which graphql.Float
+// can't serialize on its own
+func moneyField() *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.Float,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			v, err := graphql.DefaultResolveFn(p)
+			switch m := v.(type) {
+			case currency.Money:
+				return m.Float(), err
+			case *currency.Money:
+				if m == nil {
+					return nil, err
+				}
+				return m.Float(), err
+			}
+			return v, err
+		},
+	}
+}
+
+// moneyArg reads a Float argument as an amount in the base currency
+func moneyArg(v interface{}) currency.Money {
+	f, _ := v.(float64)
+	return currency.FromFloat(f, converter.Base)
+}
+
+var priceTriggerType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PriceTrigger",
+	Fields: graphql.Fields{
+		"price":    moneyField(),
+		"channels": &graphql.Field{Type: graphql.NewList(graphql.String)},
+		"fired":    &graphql.Field{Type: graphql.Boolean},
+	},
+})
+
+var scraperOptionsType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ScraperOptions",
+	Fields: graphql.Fields{
+		"methods":   &graphql.Field{Type: graphql.NewList(graphql.String)},
+		"timeout":   &graphql.Field{Type: graphql.String},
+		"retries":   &graphql.Field{Type: graphql.Int},
+		"waitFor":   &graphql.Field{Type: graphql.String},
+		"userAgent": &graphql.Field{Type: graphql.String},
+	},
+})
+
+var trackedItemType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "TrackedItem",
+	Fields: graphql.Fields{
+		"id":                       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"url":                      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"asin":                     &graphql.Field{Type: graphql.String},
+		"title":                    &graphql.Field{Type: graphql.String},
+		"imageUrl":                 &graphql.Field{Type: graphql.String},
+		"targetPrice":              moneyField(),
+		"mode":                     &graphql.Field{Type: graphql.String},
+		"locale":                   &graphql.Field{Type: graphql.String},
+		"tags":                     &graphql.Field{Type: graphql.NewList(graphql.String)},
+		"notifyFurtherDropPercent": &graphql.Field{Type: graphql.Float},
+		"minDropAmount":            moneyField(),
+		"lastAlertedPrice":         moneyField(),
+		"lastAlertedAt":            &graphql.Field{Type: graphql.String},
+		"continueAfterAlert": &graphql.Field{
+			Type: graphql.Boolean,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if item, ok := p.Source.(TrackingRequest); ok {
+					return continueAfterAlert(item), nil
+				}
+				return nil, nil
+			},
+		},
+		"triggers":                       &graphql.Field{Type: graphql.NewList(priceTriggerType)},
+		"baselinePrice":                  moneyField(),
+		"minDiscountFromBaselinePercent": &graphql.Field{Type: graphql.Float},
+		"targetCondition":                &graphql.Field{Type: graphql.String},
+		"notifyOnUptrend":                &graphql.Field{Type: graphql.Boolean},
+		"lowestInDays":                   &graphql.Field{Type: graphql.Int},
+		"lastPrice":                      moneyField(),
+		"lastPriceString":                &graphql.Field{Type: graphql.String},
+		"anomaly":                        &graphql.Field{Type: graphql.Boolean},
+		"anomalyScore":                   &graphql.Field{Type: graphql.Float},
+		"dealScore":                      &graphql.Field{Type: graphql.Float},
+		"isGoodDeal":                     &graphql.Field{Type: graphql.Boolean},
+		"listPrice":                      moneyField(),
+		"discountPercent":                &graphql.Field{Type: graphql.Float},
+		"consecutiveFailures":            &graphql.Field{Type: graphql.Int},
+		"lastError":                      &graphql.Field{Type: graphql.String},
+		"lastErrorCode":                  &graphql.Field{Type: graphql.String},
+		"note":                           &graphql.Field{Type: graphql.String},
+		"group":                          &graphql.Field{Type: graphql.String},
+		"adjustmentPercent":              &graphql.Field{Type: graphql.Float},
+		"adjustmentAmount":               moneyField(),
+		"couponCode":                     &graphql.Field{Type: graphql.String},
+		"scraperOptions":                 &graphql.Field{Type: scraperOptionsType},
+		"lastSuccessAt":                  &graphql.Field{Type: graphql.String},
+		"lastCheckedAt":                  &graphql.Field{Type: graphql.String},
+		"expiresAt":                      &graphql.Field{Type: graphql.String},
+		"cron":                           &graphql.Field{Type: graphql.String},
+		"holdWindow":                     &graphql.Field{Type: graphql.String},
+		"holdStartedAt":                  &graphql.Field{Type: graphql.String},
+		"alertWindowStart":               &graphql.Field{Type: graphql.String},
+		"alertWindowEnd":                 &graphql.Field{Type: graphql.String},
+		"alertWindowPause":               &graphql.Field{Type: graphql.Boolean},
+		"inStock": &graphql.Field{
+			Type: graphql.Boolean,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if item, ok := p.Source.(TrackingRequest); ok && item.InStock != nil {
+					return *item.InStock, nil
+				}
+				return nil, nil
+			},
+		},
+	},
+})
+
+var pricePointType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PricePoint",
+	Fields: graphql.Fields{
+		"timestamp":      &graphql.Field{Type: graphql.String},
+		"price":          moneyField(),
+		"priceString":    &graphql.Field{Type: graphql.String},
+		"currency":       &graphql.Field{Type: graphql.String},
+		"formattedPrice": &graphql.Field{Type: graphql.String},
+		"convertedPrice": moneyField(),
+		"baseCurrency":   &graphql.Field{Type: graphql.String},
+	},
+})
+
+var breakerType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "CircuitBreaker",
+	Fields: graphql.Fields{
+		"domain":              &graphql.Field{Type: graphql.String},
+		"state":               &graphql.Field{Type: graphql.String},
+		"consecutiveFailures": &graphql.Field{Type: graphql.Int},
+	},
+})
+
+var statsType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Stats",
+	Fields: graphql.Fields{
+		"trackedItems": &graphql.Field{Type: graphql.Int},
+		"clients":      &graphql.Field{Type: graphql.Int},
+		"breakers":     &graphql.Field{Type: graphql.NewList(breakerType)},
+	},
+})
+
+var queryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Query",
+	Fields: graphql.Fields{
+		"trackedItems": &graphql.Field{
+			Type: graphql.NewList(trackedItemType),
+			Args: graphql.FieldConfigArgument{
+				"tag": &graphql.ArgumentConfig{Type: graphql.String},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				tag, _ := p.Args["tag"].(string)
+				return listTrackedItems(tag)
+			},
+		},
+		"priceHistory": &graphql.Field{
+			Type: graphql.NewList(pricePointType),
+			Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				points, _, err := store.History(p.Args["id"].(string))
+				return points, err
+			},
+		},
+		"stats": &graphql.Field{
+			Type: statsType,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				itemCount, err := store.CountItems()
+				if err != nil {
+					return nil, err
+				}
+				mu.RLock()
+				clientCount := len(clients)
+				mu.RUnlock()
+
+				var list []map[string]interface{}
+				for domain, b := range breakerStates() {
+					list = append(list, map[string]interface{}{
+						"domain":              domain,
+						"state":               b.State,
+						"consecutiveFailures": b.Failures,
+					})
+				}
+				sort.Slice(list, func(i, j int) bool { return list[i]["domain"].(string) < list[j]["domain"].(string) })
+
+				return map[string]interface{}{
+					"trackedItems": itemCount,
+					"clients":      clientCount,
+					"breakers":     list,
+				}, nil
+			},
+		},
+	},
+})
+
+var mutationType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Mutation",
+	Fields: graphql.Fields{
+		"track": &graphql.Field{
+			Type: trackedItemType,
+			Args: graphql.FieldConfigArgument{
+				"url":                            &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"targetPrice":                    &graphql.ArgumentConfig{Type: graphql.Float},
+				"targetPriceString":              &graphql.ArgumentConfig{Type: graphql.String},
+				"id":                             &graphql.ArgumentConfig{Type: graphql.String},
+				"mode":                           &graphql.ArgumentConfig{Type: graphql.String},
+				"tags":                           &graphql.ArgumentConfig{Type: graphql.NewList(graphql.String)},
+				"expiresAt":                      &graphql.ArgumentConfig{Type: graphql.String},
+				"maxAgeDays":                     &graphql.ArgumentConfig{Type: graphql.Int},
+				"cron":                           &graphql.ArgumentConfig{Type: graphql.String},
+				"locale":                         &graphql.ArgumentConfig{Type: graphql.String},
+				"holdWindow":                     &graphql.ArgumentConfig{Type: graphql.String},
+				"continueAfterAlert":             &graphql.ArgumentConfig{Type: graphql.Boolean},
+				"alertWindowStart":               &graphql.ArgumentConfig{Type: graphql.String},
+				"alertWindowEnd":                 &graphql.ArgumentConfig{Type: graphql.String},
+				"alertWindowPause":               &graphql.ArgumentConfig{Type: graphql.Boolean},
+				"minDiscountFromBaselinePercent": &graphql.ArgumentConfig{Type: graphql.Float},
+				"targetCondition":                &graphql.ArgumentConfig{Type: graphql.String},
+				"notifyOnUptrend":                &graphql.ArgumentConfig{Type: graphql.Boolean},
+				"lowestInDays":                   &graphql.ArgumentConfig{Type: graphql.Int},
+				"note":                           &graphql.ArgumentConfig{Type: graphql.String},
+				"group":                          &graphql.ArgumentConfig{Type: graphql.String},
+				"adjustmentPercent":              &graphql.ArgumentConfig{Type: graphql.Float},
+				"adjustmentAmount":               &graphql.ArgumentConfig{Type: graphql.Float},
+				"couponCode":                     &graphql.ArgumentConfig{Type: graphql.String},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				req := TrackingRequest{}
+				req.URL, _ = p.Args["url"].(string)
+				req.TargetPrice = moneyArg(p.Args["targetPrice"])
+				req.TargetPriceString, _ = p.Args["targetPriceString"].(string)
+				req.ID, _ = p.Args["id"].(string)
+				req.Mode, _ = p.Args["mode"].(string)
+				req.Tags = stringArgs(p.Args["tags"])
+				req.ExpiresAt, _ = p.Args["expiresAt"].(string)
+				req.MaxAgeDays, _ = p.Args["maxAgeDays"].(int)
+				req.Cron, _ = p.Args["cron"].(string)
+				req.Locale, _ = p.Args["locale"].(string)
+				req.HoldWindow, _ = p.Args["holdWindow"].(string)
+				req.AlertWindowStart, _ = p.Args["alertWindowStart"].(string)
+				req.AlertWindowEnd, _ = p.Args["alertWindowEnd"].(string)
+				req.AlertWindowPause, _ = p.Args["alertWindowPause"].(bool)
+				req.MinDiscountFromBaselinePercent, _ = p.Args["minDiscountFromBaselinePercent"].(float64)
+				req.TargetCondition, _ = p.Args["targetCondition"].(string)
+				req.NotifyOnUptrend, _ = p.Args["notifyOnUptrend"].(bool)
+				req.LowestInDays, _ = p.Args["lowestInDays"].(int)
+				req.Note, _ = p.Args["note"].(string)
+				req.Group, _ = p.Args["group"].(string)
+				req.AdjustmentPercent, _ = p.Args["adjustmentPercent"].(float64)
+				req.AdjustmentAmount = moneyArg(p.Args["adjustmentAmount"])
+				req.CouponCode, _ = p.Args["couponCode"].(string)
+				if cont, ok := p.Args["continueAfterAlert"].(bool); ok {
+					req.ContinueAfterAlert = &cont
+				}
+				item, err := startTracking(req)
+				if err != nil {
+					return nil, err
+				}
+				startInitialCheck(context.WithoutCancel(p.Context), item)
+				return item, nil
+			},
+		},
+		"untrack": &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return stopTracking(p.Context, p.Args["id"].(string))
+			},
+		},
+		"update": &graphql.Field{
+			Type: trackedItemType,
+			Args: graphql.FieldConfigArgument{
+				"id":          &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"targetPrice": &graphql.ArgumentConfig{Type: graphql.Float},
+				"tags":        &graphql.ArgumentConfig{Type: graphql.NewList(graphql.String)},
+				"note":        &graphql.ArgumentConfig{Type: graphql.String},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				item, err := updateTracking(p.Args["id"].(string), func(item *TrackingRequest) error {
+					if price, ok := p.Args["targetPrice"].(float64); ok {
+						if price <= 0 {
+							return newAPIError(http.StatusBadRequest, ErrCodeInvalidTargetPrice, "Target price must be greater than 0")
+						}
+						item.TargetPrice = currency.FromFloat(price, converter.Base)
+						item.LastAlertedPrice = currency.Money{}
+					}
+					if tags, ok := p.Args["tags"]; ok {
+						item.Tags = normalizeTags(stringArgs(tags))
+					}
+					if note, ok := p.Args["note"].(string); ok {
+						normalized, err := normalizeNote(note)
+						if err != nil {
+							return newAPIError(http.StatusBadRequest, ErrCodeInvalidParameter, err.Error())
+						}
+						item.Note = normalized
+					}
+					return nil
+				})
+				if err != nil {
+					return nil, err
+				}
+				return item, nil
+			},
+		},
+	},
+})
+
+var graphqlSchema = mustSchema()
+
+func mustSchema() graphql.Schema {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: queryType, Mutation: mutationType})
+	if err != nil {
+		panic(fmt.Sprintf("invalid GraphQL schema: %v", err))
+	}
+	return schema
+}
+
+func stringArgs(v interface{}) []string {
+	list, _ := v.([]interface{})
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// GraphQL handler. Live alerts are available over the /ws WebSocket stream.
+func graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Query         string                 `json:"query"`
+		OperationName string                 `json:"operationName"`
+		Variables     map[string]interface{} `json:"variables"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         graphqlSchema,
+		RequestString:  req.Query,
+		OperationName:  req.OperationName,
+		VariableValues: req.Variables,
+		Context:        r.Context(),
+	})
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"time"
+
+	"price-tracker-backend/currency"
+)
+
+// groupMembers returns the tracked items in a product group
+func groupMembers(group string) ([]TrackingRequest, error) {
+	items, err := store.ListItems()
+	if err != nil {
+		return nil, err
+	}
+	var members []TrackingRequest
+	for _, item := range items {
+		if item.Group == group {
+			members = append(members, item)
+		}
+	}
+	return members, nil
+}
+
+// cheapestInGroup returns the in-stock member with the lowest last price, the
+// group's target (the lowest its members set) and the price it last alerted at
+func cheapestInGroup(members []TrackingRequest) (cheapest TrackingRequest, target, alerted currency.Money) {
+	for _, m := range members {
+		if t := alertTarget(m); t.Sign() > 0 && (target.IsZero() || t.Cmp(target) < 0) {
+			target = t
+		}
+		if m.GroupAlertedPrice.Sign() > 0 && (alerted.IsZero() || m.GroupAlertedPrice.Cmp(alerted) < 0) {
+			alerted = m.GroupAlertedPrice
+		}
+		if m.LastPrice.Sign() <= 0 || (m.InStock != nil && !*m.InStock) {
+			continue
+		}
+		if cheapest.ID == "" || m.LastPrice.Cmp(cheapest.LastPrice) < 0 {
+			cheapest = m
+		}
+	}
+	return cheapest, target, alerted
+}
+
+// checkGroupLow evaluates an item's product group as a unit after one of its
+// members was checked: when the cheapest retailer's price is at or below the
+// group's target it alerts once, naming that retailer, and again only after
+// a further drop of minDrop
+func checkGroupLow(ctx context.Context, item TrackingRequest, minDrop currency.Money) {
+	members, err := groupMembers(item.Group)
+	if err != nil {
+		logf(ctx, "Failed to load group %s: %v", item.Group, err)
+		return
+	}
+	cheapest, target, alerted := cheapestInGroup(members)
+	if cheapest.ID == "" || target.Sign() <= 0 {
+		return
+	}
+	price := cheapest.LastPrice
+	if !atOrBelow(price, target.Sub(minDrop)) {
+		if alerted.Sign() > 0 {
+			// Back above target, so the next drop below it is worth an alert again
+			setGroupAlerted(members, currency.Money{})
+		}
+		logf(ctx, "Cheapest in group %s is %s at %s, not yet at target %s", item.Group, cheapest.ID, price.Decimal(), target.Decimal())
+		return
+	}
+	if alerted.Sign() > 0 && (!atOrBelow(price, alerted.Sub(minDrop)) || atOrBelow(alerted, price)) {
+		logf(ctx, "Cheapest in group %s still at target but not far enough below last alert (%s)", item.Group, alerted.Decimal())
+		return
+	}
+
+	logf(ctx, "Group %s reached its target: %s is cheapest at %s (target %s)", item.Group, cheapest.ID, price.Decimal(), target.Decimal())
+	deliverAlert(ctx, PriceAlert{
+		ID:             cheapest.ID,
+		URL:            cheapest.URL,
+		Locale:         cheapest.Locale,
+		Title:          cheapest.Title,
+		ImageURL:       cheapest.ImageURL,
+		CurrentPrice:   price,
+		TargetPrice:    target,
+		PriceString:    cheapest.LastPriceString,
+		Currency:       cmp.Or(converter.Base, currency.Detect(cheapest.LastPriceString, cheapest.URL)),
+		ConvertedPrice: price,
+		BaseCurrency:   converter.Base,
+		Type:           AlertGroupLow,
+		Group:          item.Group,
+		Retailer:       breakerKey(cheapest.URL),
+		InStock:        true,
+		Timestamp:      time.Now().Format(time.RFC3339),
+	})
+	setGroupAlerted(members, price)
+}
+
+// setGroupAlerted records on every member the price the group last alerted at
+func setGroupAlerted(members []TrackingRequest, price currency.Money) {
+	for _, m := range members {
+		updateItemState(m.ID, func(item *TrackingRequest) {
+			item.GroupAlertedPrice = price
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"price-tracker-backend/currency"
+)
+
+// PricePoint is one observed price for a tracked item
+type PricePoint struct {
+	Timestamp string         `json:"timestamp"`
+	Price     currency.Money `json:"price"` // Price as scraped, in Currency
+	// Price after the item's coupon, in Currency, if it had one
+	EffectivePrice currency.Money `json:"effectivePrice,omitzero"`
+	PriceString    string         `json:"priceString"`
+	Currency       string         `json:"currency,omitempty"`
+	FormattedPrice string         `json:"formattedPrice,omitempty"`
+	ConvertedPrice currency.Money `json:"convertedPrice"` // Price paid, EffectivePrice or Price, normalized to BaseCurrency
+	BaseCurrency   string         `json:"baseCurrency,omitempty"`
+}
+
+// comparisonCurrency is the currency a point's ConvertedPrice is in: the base
+// currency it was converted to, or the currency it was scraped in without one
+func comparisonCurrency(p PricePoint) string {
+	return strings.ToUpper(cmp.Or(p.BaseCurrency, p.Currency))
+}
+
+// comparableHistory returns the points of history whose ConvertedPrice can be
+// compared with prices in code: those already in it, and those that can be
+// converted to it now, e.g. after a region switch or once a base currency is
+// configured. Other points are left out so history-based features never compare
+// dollars with rupees. Points without a currency are kept, as are all points
+// when code is unknown.
+func comparableHistory(history []PricePoint, code string) []PricePoint {
+	code = strings.ToUpper(code)
+	out := make([]PricePoint, 0, len(history))
+	for _, p := range history {
+		pc := comparisonCurrency(p)
+		switch {
+		case pc == "" || code == "" || pc == code:
+			out = append(out, p)
+		case code == converter.Base && p.Currency != "":
+			if converted, err := converter.Convert(cmp.Or(p.EffectivePrice, p.Price).In(p.Currency)); err == nil {
+				p.ConvertedPrice, p.BaseCurrency = converted, converter.Base
+				out = append(out, p)
+			}
+		}
+	}
+	return out
+}
+
+func recordPrice(id string, point PricePoint) {
+	if point.Timestamp == "" {
+		point.Timestamp = time.Now().Format(time.RFC3339)
+	}
+	if point.FormattedPrice == "" {
+		point.FormattedPrice = currency.Format(point.Price.Float(), point.Currency)
+	}
+	if err := store.AppendHistory(id, point); err != nil {
+		log.Printf("Failed to record price history for %s: %v", id, err)
+	}
+}
+
+// Get price history handler
+func getPriceHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+	points, hasHistory, err := store.History(id)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	_, err = store.GetItem(id)
+	if err != nil && !errors.Is(err, errItemNotFound) {
+		writeAPIError(w, err)
+		return
+	}
+	tracked := err == nil
+
+	if !hasHistory && !tracked {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("No tracked item or history for ID %q", id))
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"history": points,
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"price-tracker-backend/currency"
+)
+
+// HeldPrice is the best price seen during an item's hold window
+type HeldPrice struct {
+	Price          currency.Money `json:"price"`
+	PriceString    string         `json:"priceString"`
+	Currency       string         `json:"currency,omitempty"`
+	ConvertedPrice currency.Money `json:"convertedPrice"`
+	ObservedAt     string         `json:"observedAt"`
+	// Price as scraped when Price is after the item's coupon, see PriceAlert
+	RegularPrice currency.Money `json:"regularPrice,omitzero"`
+}
+
+// holdWindow is how long an item waits after reaching its target before alerting, 0 for no wait
+func holdWindow(item TrackingRequest) time.Duration {
+	d, err := time.ParseDuration(item.HoldWindow)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+func heldFrom(alert PriceAlert) HeldPrice {
+	return HeldPrice{
+		Price:          alert.CurrentPrice,
+		PriceString:    alert.PriceString,
+		Currency:       alert.Currency,
+		ConvertedPrice: alert.ConvertedPrice,
+		ObservedAt:     alert.Timestamp,
+		RegularPrice:   alert.RegularPrice,
+	}
+}
+
+// startHold begins the item's hold window at the current price instead of
+// alerting right away. It returns false if the item has no hold window.
+func startHold(ctx context.Context, id string, item TrackingRequest, alert PriceAlert) bool {
+	window := holdWindow(item)
+	if window <= 0 {
+		return false
+	}
+	best := heldFrom(alert)
+	updateItemState(id, func(current *TrackingRequest) {
+		current.HoldStartedAt = alert.Timestamp
+		current.HoldBest = &best
+	})
+	logf(ctx, "Price target reached for %s at %s; holding for %s to see if it drops further", id, alert.ConvertedPrice.Decimal(), window)
+	return true
+}
+
+// continueHold tracks the lowest price during an item's hold window and, once the
+// window has passed, sends one alert with that price. It returns false if the
+// item isn't holding, so the caller checks the price as usual.
+func continueHold(ctx context.Context, id string, item TrackingRequest, alert PriceAlert) bool {
+	if item.HoldStartedAt == "" || item.HoldBest == nil {
+		return false
+	}
+	best := *item.HoldBest
+	if alert.ConvertedPrice.Cmp(best.ConvertedPrice) < 0 {
+		best = heldFrom(alert)
+	}
+
+	started, err := time.Parse(time.RFC3339, item.HoldStartedAt)
+	if err == nil && time.Since(started) < holdWindow(item) {
+		updateItemState(id, func(current *TrackingRequest) {
+			current.HoldBest = &best
+		})
+		logf(ctx, "Holding alert for %s: best price so far %s", id, best.ConvertedPrice.Decimal())
+		return true
+	}
+
+	updateItemState(id, func(current *TrackingRequest) {
+		current.HoldStartedAt, current.HoldBest = "", nil
+	})
+	alert.CurrentPrice = best.Price
+	alert.PriceString = best.PriceString
+	alert.Currency = best.Currency
+	alert.ConvertedPrice = best.ConvertedPrice
+	alert.ObservedAt = best.ObservedAt
+	alert.RegularPrice = best.RegularPrice
+	logf(ctx, "Hold window for %s ended; alerting with the best price %s", id, best.ConvertedPrice.Decimal())
+	sendTargetAlert(ctx, id, item, alert)
+	return true
+}
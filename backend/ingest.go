@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"price-tracker-backend/currency"
+)
+
+// MethodExternal marks prices pushed in through /api/ingest-price rather than scraped
+const MethodExternal = "external"
+
+// IngestRequest is a price found by an external scraper for a tracked item
+type IngestRequest struct {
+	ID        string         `json:"id"`
+	Price     currency.Money `json:"price"`
+	Currency  string         `json:"currency"`            // ISO 4217 code, e.g. "INR"
+	Timestamp string         `json:"timestamp,omitempty"` // RFC 3339 time the price was seen, defaults to now
+	InStock   *bool          `json:"inStock,omitempty"`   // Defaults to true
+	Title     string         `json:"title,omitempty"`
+	ImageURL  string         `json:"imageUrl,omitempty"`
+}
+
+// ingestPriceHandler accepts a price from an external scraper, for sites this
+// server can't scrape itself, and handles it like a scraped one: the item's
+// state and history are updated and alerts are sent
+func ingestPriceHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if cfg.IngestToken == "" {
+		writeError(w, http.StatusForbidden, ErrCodeForbidden, "Price ingestion is disabled; set INGEST_TOKEN to enable it")
+		return
+	}
+	if !bearerAuthorized(r, cfg.IngestToken) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="ingest-price"`)
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "A valid bearer token is required")
+		return
+	}
+
+	var req IngestRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	observedAt := time.Now()
+	var problems validationErrors
+	if req.ID == "" {
+		problems.add("id", ErrCodeInvalidID, "ID is required")
+	}
+	if req.Price.Sign() <= 0 {
+		problems.add("price", ErrCodeInvalidParameter, "Price must be greater than 0")
+	}
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if len(req.Currency) != 3 || strings.Trim(req.Currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		problems.add("currency", ErrCodeInvalidParameter, fmt.Sprintf("Currency must be a three-letter ISO 4217 code, got %q", req.Currency))
+	}
+	if req.Timestamp != "" {
+		t, err := time.Parse(time.RFC3339, req.Timestamp)
+		switch {
+		case err != nil:
+			problems.add("timestamp", ErrCodeInvalidParameter, "Timestamp must be an RFC 3339 time, e.g. 2024-05-01T12:00:00Z")
+		case t.After(observedAt.Add(time.Minute)):
+			problems.add("timestamp", ErrCodeInvalidParameter, "Timestamp is in the future")
+		default:
+			observedAt = t
+		}
+	}
+	if err := problems.err(); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	item, err := store.GetItem(req.ID)
+	if errors.Is(err, errItemNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("No tracked item with ID %q", req.ID))
+		return
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if _, err := toBaseCurrency(req.Price, req.Currency); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, ErrCodeConversionFailed, err.Error())
+		return
+	}
+
+	price := req.Price.In(req.Currency)
+	result := ScrapeResult{
+		PriceString:  currency.Format(price.Float(), req.Currency),
+		Currency:     req.Currency,
+		Price:        price,
+		ScrapedPrice: price,
+		InStock:      req.InStock == nil || *req.InStock,
+		Title:        req.Title,
+		ImageURL:     req.ImageURL,
+		Method:       MethodExternal,
+	}
+	logf(r.Context(), "Price for %s ingested from an external scraper: %s", req.ID, result.PriceString)
+
+	ctx := context.WithoutCancel(r.Context())
+	markChecked(req.ID, nil)
+	if item.Mode == ModeAvailability {
+		applyAvailability(ctx, req.ID, item, result)
+	} else {
+		applyPrice(ctx, req.ID, item, result, observedAt)
+	}
+
+	response := map[string]interface{}{"success": true}
+	updated, err := store.GetItem(req.ID)
+	switch {
+	case errors.Is(err, errItemNotFound):
+		// The price alerted and tracking stopped
+		response["item"], response["untracked"] = item, true
+	case err != nil:
+		writeAPIError(w, err)
+		return
+	default:
+		response["item"], response["status"] = withDealRating(updated), itemStatus(updated)
+	}
+	json.NewEncoder(w).Encode(response)
+}
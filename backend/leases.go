@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// checkInterval is how often monitorPrices checks every tracked item. With
+// ITEM_LEASES enabled, a lease lasts one interval so that only one instance
+// checks a given item per interval.
+const checkInterval = 30 * time.Second
+
+var (
+	leasedChecks   = make(map[string]bool) // Items this instance is checking right now
+	leasedChecksMu sync.Mutex
+)
+
+// acquireCheckLease reports whether this instance may check the item now.
+// Without ITEM_LEASES every instance checks every item.
+func acquireCheckLease(id string) bool {
+	if !cfg.ItemLeases {
+		return true
+	}
+	ok, err := store.AcquireLease(id, cfg.InstanceID, checkInterval)
+	if err != nil {
+		log.Printf("Failed to lease item %s: %v", id, err)
+		return false
+	}
+	if ok {
+		leasedChecksMu.Lock()
+		leasedChecks[id] = true
+		leasedChecksMu.Unlock()
+	}
+	return ok
+}
+
+// finishCheckLease stops heartbeating an item once its check is done. The lease
+// itself runs until the end of the interval so other instances skip the item.
+func finishCheckLease(id string) {
+	if !cfg.ItemLeases {
+		return
+	}
+	leasedChecksMu.Lock()
+	delete(leasedChecks, id)
+	leasedChecksMu.Unlock()
+}
+
+// heartbeatLeases keeps leases alive for checks that outlast the interval, e.g.
+// slow scrapes. If this instance dies its leases simply expire.
+func heartbeatLeases() {
+	ticker := time.NewTicker(checkInterval / 3)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		leasedChecksMu.Lock()
+		ids := make([]string, 0, len(leasedChecks))
+		for id := range leasedChecks {
+			ids = append(ids, id)
+		}
+		leasedChecksMu.Unlock()
+
+		if err := store.RenewLeases(cfg.InstanceID, ids, checkInterval); err != nil {
+			log.Printf("Failed to renew %d item leases: %v", len(ids), err)
+		}
+	}
+}
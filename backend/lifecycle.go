@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"price-tracker-backend/notify"
+)
+
+// Reasons reported in tracker lifecycle notifications
+const (
+	StopUntracked     = "untracked"
+	StopTargetReached = "target reached"
+	StopBackInStock   = "back in stock"
+	StopTriggersFired = "all triggers fired"
+	StopExpired       = "expired"
+)
+
+// notifyLifecycle sends a low-priority notification that a tracker started or
+// stopped, when NOTIFY_LIFECYCLE is on. It doesn't block the caller.
+func notifyLifecycle(ctx context.Context, title, body, url string) {
+	if !cfg.NotifyLifecycle || len(notifiers) == 0 {
+		return
+	}
+	msg := notify.Message{Title: title, Body: body, URL: url, LowPriority: true}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+		notify.SendAll(ctx, notifiers, msg)
+	}()
+}
+
+// notifyStarted reports a newly tracked item
+func notifyStarted(ctx context.Context, item TrackingRequest) {
+	body := fmt.Sprintf("Now tracking %s", itemLabel(item))
+	if item.Mode == ModePrice && item.TargetPrice.Sign() > 0 {
+		body += fmt.Sprintf(" for a price at or below %.2f %s", item.TargetPrice.Float(), converter.Base)
+		if item.MinDiscountFromBaselinePercent > 0 {
+			joiner := "or"
+			if item.TargetCondition == TargetAll {
+				joiner = "and"
+			}
+			body += fmt.Sprintf(" %s %.0f%% below its first price", joiner, item.MinDiscountFromBaselinePercent)
+		}
+	} else if item.Mode == ModePrice && item.MinDiscountFromBaselinePercent > 0 {
+		body += fmt.Sprintf(" for a price %.0f%% below its first price", item.MinDiscountFromBaselinePercent)
+	} else if item.Mode == ModeAvailability {
+		body += " until it's back in stock"
+	}
+	notifyLifecycle(ctx, "Tracking started", body, item.URL)
+}
+
+// notifyStopped reports an item that's no longer tracked and why
+func notifyStopped(ctx context.Context, item TrackingRequest, reason string) {
+	notifyLifecycle(ctx, "Tracking stopped", fmt.Sprintf("Stopped tracking %s (%s)", itemLabel(item), reason), item.URL)
+}
+
+func itemLabel(item TrackingRequest) string {
+	if item.Title != "" {
+		return fmt.Sprintf("%q [%s]", item.Title, item.ID)
+	}
+	return fmt.Sprintf("%s [%s]", item.URL, item.ID)
+}
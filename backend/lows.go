@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"price-tracker-backend/currency"
+)
+
+// Longest window an item's LowestInDays may look back over
+const maxLowestInDays = 365
+
+// windowLow returns the lowest price in history from the days before now, and
+// whether the history reaches back that far. Without that, a price can't be
+// called the lowest in the window yet.
+func windowLow(history []PricePoint, days int, now time.Time) (low currency.Money, covered bool) {
+	since := now.AddDate(0, 0, -days)
+	for _, p := range history {
+		ts, err := time.Parse(time.RFC3339, p.Timestamp)
+		if err != nil || p.ConvertedPrice.Sign() <= 0 {
+			continue
+		}
+		if !ts.After(since) {
+			covered = true
+			continue
+		}
+		if low.IsZero() || p.ConvertedPrice.Cmp(low) < 0 {
+			low = p.ConvertedPrice
+		}
+	}
+	return low, covered && low.Sign() > 0
+}
+
+// checkNewLow reports whether price is the item's lowest in its LowestInDays,
+// by at least minDrop, returning the previous low it beat
+func checkNewLow(ctx context.Context, item TrackingRequest, history []PricePoint, price, minDrop currency.Money, now time.Time) (currency.Money, bool) {
+	if item.LowestInDays <= 0 {
+		return currency.Money{}, false
+	}
+	low, covered := windowLow(history, item.LowestInDays, now)
+	if !covered {
+		logf(ctx, "History of %s doesn't cover %d days yet, not looking for a new low", item.ID, item.LowestInDays)
+		return currency.Money{}, false
+	}
+	if !atOrBelow(price, low.Sub(minDrop)) || atOrBelow(low, price) {
+		return currency.Money{}, false
+	}
+	logf(ctx, "Price for %s (%s) is its lowest in %d days, below %s", item.ID, price.Decimal(), item.LowestInDays, low.Decimal())
+	return low, true
+}
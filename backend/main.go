@@ -1,232 +1,546 @@
 package main
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
-	"strconv"
+	"os"
+	"slices"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
-	"github.com/gocolly/colly/v2"
-	"github.com/gocolly/colly/v2/debug"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"github.com/rs/cors"
+
+	"price-tracker-backend/currency"
+	"price-tracker-backend/notify"
+	"price-tracker-backend/scraper"
 )
 
 type PriceCheckRequest struct {
-	URL         string  `json:"url"`
-	TargetPrice float64 `json:"targetPrice"`
+	URL               string         `json:"url"`
+	TargetPrice       currency.Money `json:"targetPrice"`
+	TargetPriceString string         `json:"targetPriceString,omitempty"` // e.g. "₹4,999", used when TargetPrice is unset
+	Locale            string         `json:"locale,omitempty"`
+	// Optional ID the immediate alert is sent under, e.g. the tracked item the
+	// user is viewing; a random one is made up without it
+	ID string `json:"id,omitempty"`
 }
 
 type PriceCheckResponse struct {
-	CurrentPrice  float64 `json:"currentPrice"`
-	TargetPrice   float64 `json:"targetPrice"`
-	IsBelowTarget bool    `json:"isBelowTarget"`
-	PriceString   string  `json:"priceString"`
-	Success       bool    `json:"success"`
-	Message       string  `json:"message"`
+	ID             string         `json:"id,omitempty"` // ID of the immediate alert, set when IsBelowTarget
+	CurrentPrice   currency.Money `json:"currentPrice"`
+	TargetPrice    currency.Money `json:"targetPrice"`
+	IsBelowTarget  bool           `json:"isBelowTarget"`
+	Title          string         `json:"title,omitempty"`
+	ImageURL       string         `json:"imageUrl,omitempty"`
+	PriceString    string         `json:"priceString"`
+	Currency       string         `json:"currency,omitempty"`
+	FormattedPrice string         `json:"formattedPrice"` // CurrentPrice written in its currency's convention, e.g. "₹60,100.00"
+	ConvertedPrice currency.Money `json:"convertedPrice"`
+	BaseCurrency   string         `json:"baseCurrency,omitempty"`
+	Method         string         `json:"method,omitempty"` // Scrape method that found the price, e.g. "html"
+	// Struck-through list price as scraped, and CurrentPrice's discount from it
+	ListPrice       currency.Money `json:"listPrice,omitzero"`
+	DiscountPercent float64        `json:"discountPercent,omitempty"`
+	Success         bool           `json:"success"`
+	Message         string         `json:"message"`
 }
 
+// Tracking modes
+const (
+	ModePrice        = "price"        // Alert when the price drops to the target
+	ModeAvailability = "availability" // Alert when an out-of-stock item is back in stock
+)
+
 type TrackingRequest struct {
-	URL         string  `json:"url"`
-	TargetPrice float64 `json:"targetPrice"`
-	ID          string  `json:"id"`
+	URL               string         `json:"url"`
+	TargetPrice       currency.Money `json:"targetPrice"`
+	TargetPriceString string         `json:"targetPriceString,omitempty"` // e.g. "₹4,999", used when TargetPrice is unset
+	ID                string         `json:"id"`
+	ASIN              string         `json:"asin,omitempty"`  // Amazon product ID, filled in from the URL
+	Title             string         `json:"title,omitempty"` // Product name, filled in by scraping
+	ImageURL          string         `json:"imageUrl,omitempty"`
+	Mode              string         `json:"mode,omitempty"`
+	InStock           *bool          `json:"inStock,omitempty"` // Last observed stock status, nil until first check
+	Tags              []string       `json:"tags,omitempty"`
+	Locale            string         `json:"locale,omitempty"` // Language of notifications, NOTIFY_LOCALE if empty
+	Note              string         `json:"note,omitempty"`   // The user's own reference, e.g. "birthday gift, need by June"
+
+	// A known coupon: prices are compared and alerted after AdjustmentPercent
+	// off, then AdjustmentAmount (in the site's currency) off, see couponPrice
+	AdjustmentPercent float64        `json:"adjustmentPercent,omitempty"`
+	AdjustmentAmount  currency.Money `json:"adjustmentAmount,omitzero"`
+	CouponCode        string         `json:"couponCode,omitempty"`
+
+	// Overrides of the global scraper settings for this item, see ItemScraperOptions
+	ScraperOptions *ItemScraperOptions `json:"scraperOptions,omitempty"`
+
+	// Items with the same Group are one product at different retailers, alerted
+	// together when the cheapest of them reaches the group's target, see checkGroupLow
+	Group             string         `json:"group,omitempty"`
+	GroupAlertedPrice currency.Money `json:"groupAlertedPrice,omitzero"` // Cheapest price the group last alerted at
+
+	// Optional end of tracking: the item is untracked with an "expired" alert at
+	// ExpiresAt (RFC 3339), or MaxAgeDays after it was added
+	ExpiresAt  string `json:"expiresAt,omitempty"`
+	MaxAgeDays int    `json:"maxAgeDays,omitempty"`
+
+	// Optional cron expression; when set the item is checked on this schedule
+	// instead of on every monitor tick
+	Cron string `json:"cron,omitempty"`
+
+	// Optional sale window, e.g. the dates of a sale: outside it the item is
+	// checked and its history kept but no alerts are sent, or with
+	// AlertWindowPause it isn't checked at all. Either bound may be left open.
+	AlertWindowStart string `json:"alertWindowStart,omitempty"`
+	AlertWindowEnd   string `json:"alertWindowEnd,omitempty"`
+	AlertWindowPause bool   `json:"alertWindowPause,omitempty"`
+
+	// Keep monitoring after an alert (the default) instead of untracking the item.
+	// Further alerts need a lower price than the last one, or the price to rise
+	// back above target and drop again, and ALERT_COOLDOWN to have passed.
+	ContinueAfterAlert *bool `json:"continueAfterAlert,omitempty"`
+	// Once alerted, alert again only when the price falls this many percent below
+	// the last alerted price. Implies ContinueAfterAlert.
+	NotifyFurtherDropPercent float64 `json:"notifyFurtherDropPercent,omitempty"`
+	// Alert when the price is this many percent below BaselinePrice, instead of
+	// or along with TargetPrice; TargetCondition says whether either ("any",
+	// the default) or both ("all") must be reached
+	MinDiscountFromBaselinePercent float64 `json:"minDiscountFromBaselinePercent,omitempty"`
+	TargetCondition                string  `json:"targetCondition,omitempty"`
+	// Also alert when the price is trending up (see UPTREND_CHECKS), for items
+	// to buy before they get dearer. UptrendAlerted is set once that alert is
+	// sent and cleared when the price drops.
+	NotifyOnUptrend bool `json:"notifyOnUptrend,omitempty"`
+	UptrendAlerted  bool `json:"uptrendAlerted,omitempty"`
+	// Also alert when the price is the lowest it's been in this many days, to
+	// catch rare lows above a target set too low. Needs that much history.
+	LowestInDays int `json:"lowestInDays,omitempty"`
+	// Only alert when the price is at least this much below the target (or the last
+	// alerted price), to ignore small fluctuations around the threshold
+	MinDropAmount    currency.Money `json:"minDropAmount,omitzero"`
+	LastAlertedPrice currency.Money `json:"lastAlertedPrice,omitzero"`
+	LastAlertedAt    string         `json:"lastAlertedAt,omitempty"`
+
+	// Optional wait (e.g. "2h") after the price first reaches target, to alert
+	// once with the lowest price seen in that time rather than the first one
+	HoldWindow    string     `json:"holdWindow,omitempty"`
+	HoldStartedAt string     `json:"holdStartedAt,omitempty"`
+	HoldBest      *HeldPrice `json:"holdBest,omitempty"`
+
+	// Tiered alerts, e.g. the dashboard at 5000 and Telegram at 4000. When set,
+	// these replace TargetPrice. Each fires once; without ContinueAfterAlert,
+	// tracking stops once every trigger has fired.
+	Triggers []PriceTrigger `json:"triggers,omitempty"`
+
+	BaselinePrice currency.Money `json:"baselinePrice,omitzero"` // First observed price, in the base currency
+	LastPrice     currency.Money `json:"lastPrice,omitzero"`     // Most recent price, in the base currency
+	// Most recent price exactly as the page displayed it, e.g. "₹1,299.00"
+	LastPriceString string `json:"lastPriceString,omitempty"`
+
+	// Struck-through list price on the page at the last check, as scraped, and
+	// the price's discount from it; 0 when the page showed none
+	ListPrice       currency.Money `json:"listPrice,omitzero"`
+	DiscountPercent float64        `json:"discountPercent,omitempty"`
+
+	// Set when the last price was more than ANOMALY_STDDEVS standard deviations
+	// from the recent history; AnomalyScore is that distance, signed
+	Anomaly      bool    `json:"anomaly"`
+	AnomalyScore float64 `json:"anomalyScore,omitempty"`
+
+	// How the last price compares to the item's history (see dealRating).
+	// Computed when items are listed rather than stored; DealScore is nil
+	// until there's enough history.
+	DealScore  *float64 `json:"dealScore,omitempty"`
+	IsGoodDeal bool     `json:"isGoodDeal"`
+
+	// Check health, maintained by the monitor
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	LastError           string `json:"lastError,omitempty"`
+	LastErrorCode       string `json:"lastErrorCode,omitempty"` // PRICE_NOT_FOUND, BLOCKED_URL or SCRAPE_FAILED
+	LastSuccessAt       string `json:"lastSuccessAt,omitempty"` // Empty until a check succeeds
+	LastCheckedAt       string `json:"lastCheckedAt,omitempty"`
+	// Set once an item that found prices before has stopped finding them and
+	// that was alerted, cleared by the next successful check
+	NeedsAttention bool `json:"needsAttention,omitempty"`
+
+	CreatedAt string `json:"createdAt,omitempty"` // When tracking started (RFC 3339), the listing's sort key
 }
 
 type PriceAlert struct {
-	ID           string  `json:"id"`
-	URL          string  `json:"url"`
-	CurrentPrice float64 `json:"currentPrice"`
-	TargetPrice  float64 `json:"targetPrice"`
-	PriceString  string  `json:"priceString"`
-	Timestamp    string  `json:"timestamp"`
+	Seq            int64          `json:"seq,omitempty"` // Position in the alert log, used to acknowledge alerts
+	ID             string         `json:"id"`
+	URL            string         `json:"url"`
+	Title          string         `json:"title,omitempty"`
+	ImageURL       string         `json:"imageUrl,omitempty"`
+	CurrentPrice   currency.Money `json:"currentPrice"`
+	TargetPrice    currency.Money `json:"targetPrice"`
+	PriceString    string         `json:"priceString"`
+	Currency       string         `json:"currency,omitempty"`
+	FormattedPrice string         `json:"formattedPrice,omitempty"` // Set when the alert is sent
+	PreviousPrice  currency.Money `json:"previousPrice,omitzero"`   // Price at the previous check, in the base currency
+	ConvertedPrice currency.Money `json:"convertedPrice"`
+	BaseCurrency   string         `json:"baseCurrency,omitempty"`
+	// Struck-through list price as scraped, in Currency, and CurrentPrice's
+	// discount from it; 0 when the page showed none
+	ListPrice       currency.Money `json:"listPrice,omitzero"`
+	DiscountPercent float64        `json:"discountPercent,omitempty"`
+	Type            string         `json:"type,omitempty"`       // One of the alert types below
+	ObservedAt      string         `json:"observedAt,omitempty"` // When the price was seen, if earlier than Timestamp (after a hold window)
+	Days            int            `json:"days,omitempty"`       // Window of a new_low alert
+	Group           string         `json:"group,omitempty"`      // Product group of a group_low alert
+	Retailer        string         `json:"retailer,omitempty"`   // Site of the cheapest member in a group_low alert
+	Locale          string         `json:"locale,omitempty"`     // Language notifications are written in
+	InStock         bool           `json:"inStock"`
+	Timestamp       string         `json:"timestamp"`
+
+	// Price as scraped, in Currency, when CurrentPrice is after the item's coupon
+	RegularPrice currency.Money `json:"regularPrice,omitzero"`
+	CouponCode   string         `json:"couponCode,omitempty"`
+
+	Channels []string `json:"-"` // Limits delivery to these channels, all if empty
 }
 
+// Alert types
+const (
+	AlertPriceDrop   = "price_drop"
+	AlertBackInStock = "back_in_stock"
+	AlertBaseline    = "baseline" // First price observed for a new item; informational only
+	AlertExpired     = "expired"  // Item reached its ExpiresAt and was untracked
+	AlertAnomaly     = "anomaly"  // Price is an outlier against the item's recent history
+	// Item that found prices before hasn't for PRICE_NOT_FOUND_ALERT_AFTER checks
+	AlertNeedsAttention = "needs_attention"
+	AlertUptrend        = "price_uptrend" // Price rose over the last checks of an item with NotifyOnUptrend
+	AlertNewLow         = "new_low"       // Lowest price in the LowestInDays of an item
+	AlertGroupLow       = "group_low"     // Cheapest retailer in a product group reached the group's target
+)
+
 type Client struct {
-	conn *websocket.Conn
-	send chan PriceAlert
+	conn    *websocket.Conn
+	codec   frameCodec // JSON or MessagePack, see codecFor
+	send    chan PriceAlert
+	done    chan struct{}               // Closed when the client is dropped for falling behind
+	replies chan map[string]interface{} // Responses to the client's commands
+	itemID  string                      // When set, only alerts for this item are sent to the client
+
+	checkMu   sync.Mutex // Guards the on-demand check rate limit
+	checking  bool
+	nextCheck time.Time
 }
 
 var (
-	clients       = make(map[*Client]bool)
-	trackingItems = make(map[string]TrackingRequest)
-	mu            sync.RWMutex
-	upgrader      = websocket.Upgrader{
+	clients  = make(map[*Client]bool)
+	mu       sync.RWMutex // Guards clients
+	upgrader = websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool {
 			return true // Allow all origins for development
 		},
+		Subprotocols:     []string{wsProtocolMsgpack, wsProtocolJSON},
+		HandshakeTimeout: cfg.WSHandshakeTimeout,
 	}
+	converter = currency.NewConverter(cfg.BaseCurrency, cfg.CurrencyRates, cfg.ExchangeRateURL)
 )
 
 func main() {
+	testNotifiersFlag := flag.Bool("test-notifiers", false, "send a test message through each configured notifier and exit")
+	flag.Parse()
+
+	scraper.DefaultOptions.UserAgent = cfg.ScraperUserAgent
+	scraper.DefaultOptions.Accept = cfg.ScraperAccept
+	scraper.DefaultOptions.AcceptLanguage = cfg.ScraperAcceptLanguage
+	scraper.DefaultOptions.MaxBodySize = cfg.MaxScrapeBody
+
+	if *testNotifiersFlag {
+		fmt.Println("Testing notifiers...")
+		if !testNotifiers() {
+			os.Exit(1)
+		}
+		return
+	}
+
 	r := mux.NewRouter()
 	r.HandleFunc("/api/check-price", checkPriceHandler).Methods("POST")
 	r.HandleFunc("/api/track-price", trackPriceHandler).Methods("POST")
 	r.HandleFunc("/api/untrack-price", untrackPriceHandler).Methods("POST")
+	r.HandleFunc("/api/untrack-batch", untrackBatchHandler).Methods("POST")
 	r.HandleFunc("/api/tracked-items", getTrackedItemsHandler).Methods("GET")
+	r.HandleFunc("/api/tracked-items/{id}", getTrackedItemHandler).Methods("GET")
+	r.HandleFunc("/api/tracked-items/{id}/check", checkItemHandler).Methods("POST")
+	r.HandleFunc("/api/diagnostics/{id}", diagnosticsHandler).Methods("GET")
+	r.HandleFunc("/api/ingest-price", ingestPriceHandler).Methods("POST")
+	r.HandleFunc("/api/price-history/{id}", getPriceHistoryHandler).Methods("GET")
+	r.HandleFunc("/api/compare", compareHandler).Methods("GET")
+	r.HandleFunc("/api/trends", trendsHandler).Methods("GET")
+	r.HandleFunc("/api/alerts/unread", getUnreadAlertsHandler).Methods("GET")
+	r.HandleFunc("/api/alerts/ack", ackAlertsHandler).Methods("POST")
+	r.HandleFunc("/graphql", graphqlHandler).Methods("POST")
 	r.HandleFunc("/ws", handleWebSocket)
 	r.HandleFunc("/api/health", healthHandler).Methods("GET")
+	r.HandleFunc("/api/stats", statsHandler).Methods("GET")
+	r.HandleFunc("/api/config", configHandler).Methods("GET")
+	r.HandleFunc("/api/proxies/status", proxiesStatusHandler).Methods("GET")
+	r.Use(withRateLimit)
 
 	// Start price monitoring goroutine
 	go monitorPrices()
+	if cfg.ItemLeases {
+		log.Printf("Item leasing enabled as instance %s", cfg.InstanceID)
+		go heartbeatLeases()
+	}
+
+	// Keep price history bounded
+	go compactHistory()
+
+	// Deliver alerts held back during quiet hours
+	go flushPendingAlerts()
+
+	// Keep exchange rates fresh when an API is configured
+	go converter.StartRefreshing(6*time.Hour, nil)
 
 	// Setup CORS
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"http://localhost:3000"},
 		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders: []string{"*"},
+		ExposedHeaders: []string{"X-Request-ID"},
 	})
 
-	handler := c.Handler(r)
+	handler := withRequestID(c.Handler(r))
 
-	fmt.Println("Starting server on :8080...")
-	log.Fatal(http.ListenAndServe(":8080", handler))
+	log.Fatal(serve(handler))
 }
 
+// healthHandler answers 200 even while degraded, since tracking carries on from
+// memory; "status" tells monitoring that the store needs attention
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
+	monitor, stuck := monitorHealth(time.Now())
+	response := map[string]interface{}{"status": "healthy", "monitor": monitor}
+	if fs, ok := store.(*fallbackStore); ok {
+		if degraded, since, pending := fs.Degraded(); degraded {
+			response["status"] = "degraded"
+			response["store"] = "unavailable"
+			response["degradedSince"] = since
+			response["pendingWrites"] = pending
+		}
+	}
+	// A stuck monitor means prices are no longer watched at all
+	if stuck {
+		response["status"] = "unhealthy"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	itemCount, err := store.CountItems()
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	mu.RLock()
+	clientCount := len(clients)
+	mu.RUnlock()
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":       true,
+		"trackedItems":  itemCount,
+		"clients":       clientCount,
+		"breakers":      breakerStates(),
+		"scrapeMethods": methodStats(),
+		"alerts":        dedupeStats(),
+	})
 }
 
 func checkPriceHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var req PriceCheckRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	if req.URL == "" || req.TargetPrice <= 0 {
-		response := PriceCheckResponse{
-			Success: false,
-			Message: "Invalid URL or target price",
+	var problems validationErrors
+	priceErr := resolveTargetPrice(&req.TargetPrice, &req.TargetPriceString)
+	if priceErr != nil {
+		problems.add("targetPriceString", ErrCodeInvalidTargetPrice, priceErr.Error())
+	} else if req.TargetPrice.Sign() <= 0 {
+		problems.add("targetPrice", ErrCodeInvalidTargetPrice, "Target price must be greater than 0")
+	}
+	if req.URL == "" {
+		problems.add("url", ErrCodeInvalidURL, "URL is required")
+	} else if normalized, err := normalizeURL(req.URL); err != nil {
+		problems.add("url", ErrCodeInvalidURL, err.Error())
+	} else if err := checkScrapeTarget(r.Context(), normalized); errors.Is(err, errBlockedTarget) {
+		problems.add("url", ErrCodeBlockedURL, err.Error())
+	} else {
+		req.URL = normalized
+	}
+	if req.Locale != "" {
+		locale, ok := notify.NormalizeLocale(req.Locale)
+		if !ok {
+			problems.add("locale", ErrCodeInvalidParameter, fmt.Sprintf("Unsupported locale %q, expected one of %v", req.Locale, notify.Locales()))
 		}
-		json.NewEncoder(w).Encode(response)
+		req.Locale = locale
+	}
+	if req.ID != "" && !validID.MatchString(req.ID) {
+		problems.add("id", ErrCodeInvalidID, "Invalid ID: use 1-64 letters, digits, '-' or '_', or omit it to have one generated")
+	}
+	if err := problems.err(); err != nil {
+		writeAPIError(w, err)
 		return
 	}
 
-	priceString, currentPrice, err := scrapePrice(req.URL)
+	result, err := scrapePrice(r.Context(), req.URL)
 	if err != nil {
-		response := PriceCheckResponse{
-			Success: false,
-			Message: fmt.Sprintf("Unable to fetch price: %v", err),
-		}
-		json.NewEncoder(w).Encode(response)
+		writeAPIError(w, scrapeAPIError(err))
+		return
+	}
+
+	priceString, currentPrice := result.PriceString, result.Price
+	code, convertedPrice, err := normalizePrice(req.URL, priceString, currentPrice)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, ErrCodeConversionFailed, fmt.Sprintf("Unable to convert price: %v", err))
 		return
 	}
 
-	isBelowTarget := currentPrice <= req.TargetPrice
+	isBelowTarget := atOrBelow(convertedPrice, req.TargetPrice)
 
 	response := PriceCheckResponse{
-		CurrentPrice:  currentPrice,
-		TargetPrice:   req.TargetPrice,
-		IsBelowTarget: isBelowTarget,
-		PriceString:   priceString,
-		Success:       true,
-		Message:       "Price check successful",
+		CurrentPrice:    currentPrice,
+		TargetPrice:     req.TargetPrice,
+		IsBelowTarget:   isBelowTarget,
+		Title:           result.Title,
+		ImageURL:        result.ImageURL,
+		PriceString:     priceString,
+		Currency:        code,
+		FormattedPrice:  currency.Format(currentPrice.Float(), code),
+		ConvertedPrice:  convertedPrice,
+		BaseCurrency:    converter.Base,
+		Method:          result.Method,
+		ListPrice:       result.ListPrice,
+		DiscountPercent: result.DiscountPercent,
+		Success:         true,
+		Message:         "Price check successful",
 	}
 
 	// If price is below target, send notification immediately
 	if isBelowTarget {
-		// Generate a temporary ID for this check
-		tempID := fmt.Sprintf("check-%d", time.Now().Unix())
+		alertID := cmp.Or(req.ID, newID())
+		response.ID = alertID
+
+		// Repeated checks of the same URL and target alert once per window
+		if !immediateAlertDue(req.URL, req.TargetPrice, time.Now()) {
+			logf(r.Context(), "Skipping immediate alert for %s, one was sent within %s", req.URL, cfg.ImmediateAlertWindow)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
 
 		// Send notification without adding to tracking
+		ctx := context.WithoutCancel(r.Context())
 		go func() {
 			alert := PriceAlert{
-				ID:           tempID,
-				URL:          req.URL,
-				CurrentPrice: currentPrice,
-				TargetPrice:  req.TargetPrice,
-				PriceString:  priceString,
-				Timestamp:    time.Now().Format(time.RFC3339),
+				ID:              alertID,
+				URL:             req.URL,
+				Locale:          req.Locale,
+				Title:           result.Title,
+				ImageURL:        result.ImageURL,
+				CurrentPrice:    currentPrice,
+				TargetPrice:     req.TargetPrice,
+				PriceString:     priceString,
+				Currency:        code,
+				ConvertedPrice:  convertedPrice,
+				ListPrice:       result.ListPrice,
+				DiscountPercent: result.DiscountPercent,
+				BaseCurrency:    converter.Base,
+				Type:            AlertPriceDrop,
+				InStock:         result.InStock,
+				Timestamp:       time.Now().Format(time.RFC3339),
 			}
 
-			// Send to all connected WebSocket clients
-			mu.RLock()
-			clientCount := len(clients)
-			log.Printf("Sending immediate alert to %d connected clients", clientCount)
-			for client := range clients {
-				select {
-				case client.send <- alert:
-					log.Printf("Immediate alert sent to client successfully")
-				default:
-					log.Printf("Client channel full, closing connection")
-					close(client.send)
-					delete(clients, client)
-				}
+			if deliverAlert(ctx, alert) {
+				logf(ctx, "Immediate price alert sent for %s: ₹%s (target: ₹%s)", req.URL, priceString, req.TargetPrice.Decimal())
 			}
-			mu.RUnlock()
-
-			log.Printf("Immediate price alert sent for %s: ₹%s (target: ₹%.2f)", req.URL, priceString, req.TargetPrice)
 		}()
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
-func scrapePrice(url string) (string, float64, error) {
-	c := colly.NewCollector(
-		colly.Debugger(&debug.LogDebugger{}),
-	)
-
-	// Add multiple domains to avoid blocking
-	c.AllowedDomains = []string{"www.amazon.in", "amazon.in"}
-
-	var priceString string
+// Send an alert to all connected WebSocket clients. Alerts other than baselines
+// are also logged so disconnected clients can fetch them later.
+func broadcastAlert(alert PriceAlert) {
+	if alert.FormattedPrice == "" && alert.CurrentPrice.Sign() > 0 {
+		alert.FormattedPrice = currency.Format(alert.CurrentPrice.Float(), alert.Currency)
+	}
+	if alert.Type != AlertBaseline {
+		recordAlert(&alert)
+	}
 
-	// Multiple selectors to try
-	c.OnHTML(".a-price-whole, .a-price-range .a-offscreen, .a-price .a-offscreen, .a-price-symbol + .a-price-whole", func(e *colly.HTMLElement) {
-		if priceString == "" {
-			priceString = strings.TrimSpace(e.Text)
+	// Sent without holding mu, so waiting on slow clients doesn't block
+	// connects, disconnects or other broadcasts
+	mu.RLock()
+	log.Printf("Sending alert to %d connected clients", len(clients))
+	var targets []*Client
+	for client := range clients {
+		if client.itemID == "" || client.itemID == alert.ID {
+			targets = append(targets, client)
 		}
-	})
-
-	// Set realistic headers to avoid detection
-	c.OnRequest(func(r *colly.Request) {
-		r.Headers.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
-		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
-		r.Headers.Set("Accept-Language", "en-US,en;q=0.5")
-		r.Headers.Set("Accept-Encoding", "gzip, deflate")
-		r.Headers.Set("Upgrade-Insecure-Requests", "1")
-	})
-
-	c.OnError(func(r *colly.Response, err error) {
-		log.Printf("Error occurred: %v", err)
-	})
-
-	// Add delay to avoid rate limiting
-	c.Limit(&colly.LimitRule{
-		DomainGlob:  "*amazon.*",
-		Parallelism: 1,
-		Delay:       2 * time.Second,
-	})
-
-	err := c.Visit(url)
-	if err != nil {
-		return "", 0, err
 	}
+	mu.RUnlock()
 
-	if priceString == "" {
-		return "", 0, fmt.Errorf("price not found")
+	var slow []*Client
+	for _, client := range targets {
+		select {
+		case client.send <- alert:
+			log.Printf("Alert sent to client successfully")
+		case <-client.done:
+		default:
+			slow = append(slow, client)
+		}
 	}
 
-	// Parse Indian price format (e.g., "60,100" to 60100)
-	cleanPrice := strings.ReplaceAll(priceString, ",", "")
-	cleanPrice = strings.ReplaceAll(cleanPrice, "₹", "")
-	cleanPrice = strings.TrimSpace(cleanPrice)
-
-	price, err := strconv.ParseFloat(cleanPrice, 64)
-	if err != nil {
-		return priceString, 0, fmt.Errorf("failed to parse price: %v", err)
+	// Give clients with a full buffer a moment to catch up before dropping them;
+	// all slow clients share one deadline so a broadcast never blocks for long
+	deadline := time.NewTimer(cfg.WSSendTimeout)
+	defer deadline.Stop()
+	var dropped []*Client
+	for _, client := range slow {
+		select {
+		case client.send <- alert:
+			log.Printf("Alert sent to slow client after waiting")
+		case <-client.done:
+		case <-deadline.C:
+			log.Printf("Client channel still full after %s, closing connection", cfg.WSSendTimeout)
+			dropped = append(dropped, client)
+			deadline.Reset(0) // Expired for every remaining slow client too
+		}
+	}
+	if len(dropped) == 0 {
+		return
 	}
 
-	return priceString, price, nil
+	mu.Lock()
+	defer mu.Unlock()
+	for _, client := range dropped {
+		// Another broadcast may have dropped it meanwhile
+		if clients[client] {
+			delete(clients, client)
+			close(client.done)
+		}
+	}
 }
 
 // WebSocket handler
@@ -240,8 +554,15 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("WebSocket connection established successfully")
 	client := &Client{
-		conn: conn,
-		send: make(chan PriceAlert, 256),
+		conn:    conn,
+		codec:   codecFor(conn, r),
+		send:    make(chan PriceAlert, cfg.WSSendBuffer),
+		done:    make(chan struct{}),
+		replies: make(chan map[string]interface{}, 8),
+		itemID:  r.URL.Query().Get("id"),
+	}
+	if client.itemID != "" {
+		log.Printf("WebSocket client subscribed to item %s", client.itemID)
 	}
 
 	mu.Lock()
@@ -263,13 +584,16 @@ func (c *Client) writePump() {
 
 	for {
 		select {
-		case alert, ok := <-c.send:
-			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+		case <-c.done:
+			c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
+		case alert := <-c.send:
+			if err := c.codec.WriteFrame(c.conn, alert); err != nil {
+				log.Printf("WebSocket write error: %v", err)
 				return
 			}
-
-			if err := c.conn.WriteJSON(alert); err != nil {
+		case reply := <-c.replies:
+			if err := c.codec.WriteFrame(c.conn, reply); err != nil {
 				log.Printf("WebSocket write error: %v", err)
 				return
 			}
@@ -279,15 +603,17 @@ func (c *Client) writePump() {
 
 func (c *Client) readPump() {
 	defer c.conn.Close()
+	c.conn.SetReadLimit(4096)
 
 	for {
-		_, _, err := c.conn.ReadMessage()
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
+		c.handleCommand(data)
 	}
 }
 
@@ -296,28 +622,205 @@ func trackPriceHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var req TrackingRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	if req.URL == "" || req.TargetPrice <= 0 || req.ID == "" {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"message": "Invalid URL, target price, or ID",
-		})
+	item, err := startTracking(req)
+	if err != nil {
+		writeAPIError(w, err)
 		return
 	}
 
-	mu.Lock()
-	trackingItems[req.ID] = req
-	mu.Unlock()
+	response := map[string]interface{}{
+		"success":    true,
+		"message":    "Price tracking started",
+		"id":         item.ID,
+		"priceState": "pending",
+	}
 
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"message": "Price tracking started",
-		"id":      req.ID,
-	})
+	// Report the first price if the initial scrape finishes in time; otherwise the
+	// client learns it from the baseline message or /api/price-history later
+	select {
+	case <-startInitialCheck(context.WithoutCancel(r.Context()), item):
+		if points, ok, err := store.History(item.ID); err == nil && ok {
+			first := points[len(points)-1]
+			response["priceState"] = "available"
+			response["currentPrice"] = first.Price
+			response["priceString"] = first.PriceString
+			response["currency"] = first.Currency
+			response["formattedPrice"] = first.FormattedPrice
+			response["convertedPrice"] = first.ConvertedPrice
+		}
+		if current, err := store.GetItem(item.ID); err == nil && current.InStock != nil {
+			response["inStock"] = *current.InStock
+		}
+	case <-time.After(cfg.InitialScrapeWait):
+	}
+
+	if response["priceState"] == "pending" {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// startTracking validates a tracking request and adds it to the monitored items
+func startTracking(req TrackingRequest) (TrackingRequest, error) {
+	var problems validationErrors
+	priceErr := resolveTargetPrice(&req.TargetPrice, &req.TargetPriceString)
+	if priceErr != nil {
+		problems.add("targetPriceString", ErrCodeInvalidTargetPrice, priceErr.Error())
+	}
+
+	if req.Mode == "" {
+		req.Mode = ModePrice
+	}
+	if req.Mode != ModePrice && req.Mode != ModeAvailability {
+		problems.add("mode", ErrCodeInvalidParameter, fmt.Sprintf("Invalid mode %q, expected %q or %q", req.Mode, ModePrice, ModeAvailability))
+	}
+
+	if req.URL == "" {
+		problems.add("url", ErrCodeInvalidURL, "URL is required")
+	} else if normalized, err := normalizeURL(req.URL); err != nil {
+		problems.add("url", ErrCodeInvalidURL, err.Error())
+	} else if err := checkScrapeTarget(context.Background(), normalized); errors.Is(err, errBlockedTarget) {
+		problems.add("url", ErrCodeBlockedURL, err.Error())
+	} else {
+		req.URL = normalized
+		req.ASIN = amazonASIN(req.URL)
+	}
+	if err := validateTriggers(req.Triggers); err != nil {
+		problems.add("triggers", ErrCodeInvalidParameter, err.Error())
+	}
+	if req.MinDiscountFromBaselinePercent < 0 || req.MinDiscountFromBaselinePercent >= 100 {
+		problems.add("minDiscountFromBaselinePercent", ErrCodeInvalidParameter, "minDiscountFromBaselinePercent must be between 0 and 100")
+	}
+	if req.LowestInDays < 0 || req.LowestInDays > maxLowestInDays {
+		problems.add("lowestInDays", ErrCodeInvalidParameter, fmt.Sprintf("lowestInDays must be between 0 and %d", maxLowestInDays))
+	}
+	if !validTargetCondition(req.TargetCondition) {
+		problems.add("targetCondition", ErrCodeInvalidParameter, fmt.Sprintf("Invalid targetCondition %q, expected %q or %q", req.TargetCondition, TargetAny, TargetAll))
+	}
+	// Availability tracking doesn't need a target price, triggers bring their own
+	// and a discount from baseline or new lows can stand in for one
+	if req.Mode == ModePrice && req.TargetPrice.Sign() <= 0 && len(req.Triggers) == 0 && req.MinDiscountFromBaselinePercent <= 0 && req.LowestInDays <= 0 && priceErr == nil {
+		problems.add("targetPrice", ErrCodeInvalidTargetPrice, "Target price must be greater than 0")
+	}
+	if req.ID != "" && !validID.MatchString(req.ID) {
+		problems.add("id", ErrCodeInvalidID, "Invalid ID: use 1-64 letters, digits, '-' or '_', or omit it to have one generated")
+	}
+	if req.NotifyFurtherDropPercent < 0 || req.NotifyFurtherDropPercent >= 100 {
+		problems.add("notifyFurtherDropPercent", ErrCodeInvalidParameter, "notifyFurtherDropPercent must be between 0 and 100")
+	}
+	if req.MinDropAmount.Sign() < 0 {
+		problems.add("minDropAmount", ErrCodeInvalidParameter, "minDropAmount cannot be negative")
+	}
+	if err := resolveExpiry(&req, time.Now()); err != nil {
+		field := "expiresAt"
+		if req.MaxAgeDays < 0 {
+			field = "maxAgeDays"
+		}
+		problems.add(field, ErrCodeInvalidParameter, err.Error())
+	}
+	if err := resolveAlertWindow(&req); err != nil {
+		problems.add("alertWindow", ErrCodeInvalidParameter, err.Error())
+	}
+	if req.Cron != "" {
+		if _, err := parseCron(req.Cron); err != nil {
+			problems.add("cron", ErrCodeInvalidParameter, err.Error())
+		}
+	}
+	if req.Locale != "" {
+		locale, ok := notify.NormalizeLocale(req.Locale)
+		if !ok {
+			problems.add("locale", ErrCodeInvalidParameter, fmt.Sprintf("Unsupported locale %q, expected one of %v", req.Locale, notify.Locales()))
+		}
+		req.Locale = locale
+	}
+	validateCoupon(&req, &problems)
+	if req.ScraperOptions != nil {
+		if err := req.ScraperOptions.validate(req.URL); err != nil {
+			problems.add("scraperOptions", ErrCodeInvalidParameter, err.Error())
+		}
+	}
+	if req.Group != "" && !validID.MatchString(req.Group) {
+		problems.add("group", ErrCodeInvalidParameter, "Invalid group: use 1-64 letters, digits, '-' or '_'")
+	}
+	if note, err := normalizeNote(req.Note); err != nil {
+		problems.add("note", ErrCodeInvalidParameter, err.Error())
+	} else {
+		req.Note = note
+	}
+	if req.HoldWindow != "" {
+		if d, err := time.ParseDuration(req.HoldWindow); err != nil || d < 0 {
+			problems.add("holdWindow", ErrCodeInvalidParameter, fmt.Sprintf("Invalid holdWindow %q, expected a duration such as \"30m\" or \"2h\"", req.HoldWindow))
+		}
+	}
+	if err := problems.err(); err != nil {
+		return req, err
+	}
+	if req.ID == "" {
+		req.ID = newID()
+	}
+	req.InStock = nil
+	req.Title, req.ImageURL = "", ""
+	req.LastAlertedPrice, req.LastAlertedAt = currency.Money{}, ""
+	req.HoldStartedAt, req.HoldBest = "", nil
+	req.BaselinePrice, req.LastPrice, req.LastPriceString = currency.Money{}, currency.Money{}, ""
+	req.ListPrice, req.DiscountPercent = currency.Money{}, 0
+	req.Anomaly, req.AnomalyScore = false, 0
+	req.UptrendAlerted = false
+	req.GroupAlertedPrice = currency.Money{}
+	req.DealScore, req.IsGoodDeal = nil, false
+	req.ConsecutiveFailures, req.LastError, req.LastErrorCode, req.LastSuccessAt, req.LastCheckedAt = 0, "", "", "", ""
+	req.NeedsAttention = false
+	req.Tags = normalizeTags(req.Tags)
+	req.CreatedAt = time.Now().Format(time.RFC3339Nano)
+
+	if existing, err := findTrackedURL(req.URL, req.Mode); err != nil {
+		return req, err
+	} else if existing != nil && existing.ID != req.ID {
+		return req, newAPIError(http.StatusConflict, ErrCodeAlreadyTracked, fmt.Sprintf("This product is already tracked as %q", existing.ID))
+	}
+
+	if err := store.PutItem(req, cfg.MaxTrackedItems); err != nil {
+		if errors.Is(err, errStoreFull) {
+			return req, newAPIError(http.StatusTooManyRequests, ErrCodeLimitExceeded, fmt.Sprintf("Tracking limit reached: at most %d items can be tracked at once", cfg.MaxTrackedItems))
+		}
+		return req, err
+	}
+
+	notifyStarted(context.Background(), req)
+	return req, nil
+}
+
+// startInitialCheck captures a baseline right away instead of waiting for the
+// next monitor tick. The returned channel is closed once the check is done.
+func startInitialCheck(ctx context.Context, item TrackingRequest) <-chan struct{} {
+	done := make(chan struct{})
+	if !cfg.InitialScrapeOnTrack {
+		close(done)
+		return done
+	}
+	go func() {
+		defer close(done)
+		checkAndNotify(ctx, item.ID, item)
+	}()
+	return done
+}
+
+// findTrackedURL returns the item tracking a (normalized) URL in a mode, if any
+func findTrackedURL(url, mode string) (*TrackingRequest, error) {
+	items, err := store.ListItems()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if item.URL == url && item.Mode == mode {
+			return &item, nil
+		}
+	}
+	return nil, nil
 }
 
 // Untrack price handler
@@ -327,14 +830,25 @@ func untrackPriceHandler(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		ID string `json:"id"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.ID == "" {
+		var problems validationErrors
+		problems.add("id", ErrCodeInvalidID, "ID is required")
+		writeAPIError(w, problems.err())
 		return
 	}
 
-	mu.Lock()
-	delete(trackingItems, req.ID)
-	mu.Unlock()
+	deleted, err := stopTracking(r.Context(), req.ID)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if !deleted {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("No tracked item with ID %q", req.ID))
+		return
+	}
 
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
@@ -342,85 +856,672 @@ func untrackPriceHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// Bulk untrack handler: removes every item listed in ids or carrying one of tags
+func untrackBatchHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		IDs  []string `json:"ids"`
+		Tags []string `json:"tags"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if len(req.IDs) == 0 && len(req.Tags) == 0 {
+		var problems validationErrors
+		problems.add("ids", ErrCodeInvalidParameter, "Provide ids, tags or both")
+		writeAPIError(w, problems.err())
+		return
+	}
+
+	deleted, err := store.DeleteItems(req.IDs, normalizeTags(req.Tags))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	log.Printf("Bulk untrack removed %d items", len(deleted))
+	if len(deleted) > 0 {
+		notifyLifecycle(r.Context(), "Tracking stopped", fmt.Sprintf("Stopped tracking %d items (%s): %s", len(deleted), StopUntracked, strings.Join(deleted, ", ")), "")
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Stopped tracking %d items", len(deleted)),
+		"deleted": len(deleted),
+		"ids":     deleted,
+	})
+}
+
+// stopTracking removes an item from monitoring and reports whether it was tracked
+func stopTracking(ctx context.Context, id string) (bool, error) {
+	item, err := store.GetItem(id)
+	if err != nil && !errors.Is(err, errItemNotFound) {
+		return false, err
+	}
+	deleted, err := store.DeleteItem(id)
+	if err == nil && deleted {
+		notifyStopped(ctx, item, StopUntracked)
+	}
+	return deleted, err
+}
+
+// updateTracking atomically applies changes to a tracked item
+func updateTracking(id string, apply func(item *TrackingRequest) error) (TrackingRequest, error) {
+	item, err := store.UpdateItem(id, apply)
+	if errors.Is(err, errItemNotFound) {
+		return item, newAPIError(http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("No tracked item with ID %q", id))
+	}
+	return item, err
+}
+
 // Get tracked items handler
 func getTrackedItemsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	mu.RLock()
-	items := make([]TrackingRequest, 0, len(trackingItems))
-	for _, item := range trackingItems {
-		items = append(items, item)
+	query := r.URL.Query()
+	items, err := listTrackedItems(query.Get("tag"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	response := map[string]interface{}{"success": true}
+	// Without limit or cursor every item is returned, as before pagination
+	if query.Has("limit") || query.Has("cursor") {
+		limit, err := parsePageSize(query.Get("limit"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, err.Error())
+			return
+		}
+		var next string
+		items, next, err = pageItems(items, query.Get("cursor"), limit)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "Invalid cursor; use the nextCursor of a previous page")
+			return
+		}
+		if next != "" {
+			response["nextCursor"] = next
+		}
+	}
+	response["items"] = items
+	json.NewEncoder(w).Encode(response)
+}
+
+// Get tracked item handler: one item's full state
+func getTrackedItemHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+	item, err := store.GetItem(id)
+	if errors.Is(err, errItemNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("No tracked item with ID %q", id))
+		return
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
 	}
-	mu.RUnlock()
 
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"items":   items,
+		"item":    withDealRating(item),
+		"status":  itemStatus(item),
 	})
 }
 
+// itemStatus summarizes an item's check health: "pending" before its first
+// check, "failing" while the latest checks fail, otherwise "ok"
+func itemStatus(item TrackingRequest) string {
+	switch {
+	case item.LastCheckedAt == "":
+		return "pending"
+	case item.ConsecutiveFailures > 0:
+		return "failing"
+	default:
+		return "ok"
+	}
+}
+
+// listTrackedItems returns the tracked items, optionally only those with a tag,
+// oldest first
+func listTrackedItems(tag string) ([]TrackingRequest, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+
+	all, err := store.ListItems()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]TrackingRequest, 0, len(all))
+	for _, item := range all {
+		if tag != "" && !slices.Contains(item.Tags, tag) {
+			continue
+		}
+		items = append(items, withDealRating(item))
+	}
+	sortItems(items)
+	return items, nil
+}
+
+// resolveTargetPrice fills in a missing numeric target price from its string form.
+// A numeric target price wins when both are given. The string is cleared either way.
+func resolveTargetPrice(price *currency.Money, priceString *string) error {
+	s := strings.TrimSpace(*priceString)
+	*priceString = ""
+	if !price.IsZero() || s == "" {
+		return nil
+	}
+	parsed, err := scraper.ParsePriceString(s)
+	if err != nil {
+		return fmt.Errorf("Invalid targetPriceString %q", s)
+	}
+	*price = parsed
+	return nil
+}
+
+// Longest note an item may carry, in characters
+const maxNoteLength = 500
+
+// normalizeNote trims a note and checks its length
+func normalizeNote(note string) (string, error) {
+	note = strings.TrimSpace(note)
+	if n := utf8.RuneCountInString(note); n > maxNoteLength {
+		return "", fmt.Errorf("note is %d characters long, at most %d are allowed", n, maxNoteLength)
+	}
+	return note, nil
+}
+
+// normalizeTags lowercases and trims tags, dropping empties and duplicates
+func normalizeTags(tags []string) []string {
+	var out []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(out, tag) {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
 // Monitor prices continuously
 func monitorPrices() {
-	ticker := time.NewTicker(30 * time.Second) // Check every 30 seconds
+	ticker := time.NewTicker(checkInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			mu.RLock()
-			for id, item := range trackingItems {
-				go checkAndNotify(id, item)
+			items, err := store.ListItems()
+			if err != nil {
+				log.Printf("Failed to list tracked items: %v", err)
+				continue
+			}
+			now := time.Now()
+			checked := 0
+			for _, item := range items {
+				if isExpired(item, now) {
+					expireItem(item)
+					continue
+				}
+				if !isDue(item, now) || isPausedOutsideWindow(item, now) {
+					continue
+				}
+				if !acquireCheckLease(item.ID) {
+					continue
+				}
+				checked++
+				go func(item TrackingRequest) {
+					defer finishCheckLease(item.ID)
+					checkAndNotify(context.Background(), item.ID, item)
+				}(item)
 			}
-			mu.RUnlock()
+			recordTick(time.Now(), checked)
 		}
 	}
 }
 
-func checkAndNotify(id string, item TrackingRequest) {
-	log.Printf("Checking price for item %s: %s (target: %.2f)", id, item.URL, item.TargetPrice)
-	priceString, currentPrice, err := scrapePrice(item.URL)
+// atOrBelow reports whether price has reached target. Both are Money, so
+// they compare exactly in minor units; cfg.PriceEpsilon lets prices that many
+// whole minor units above the target count too.
+func atOrBelow(price, target currency.Money) bool {
+	return price.Cmp(target.Add(priceEpsilon())) <= 0
+}
+
+// priceEpsilon is cfg.PriceEpsilon in whole minor units of the base currency,
+// so the default half a paisa or cent allows none
+func priceEpsilon() currency.Money {
+	scale := math.Pow10(currency.MinorDigits(converter.Base))
+	// The small offset keeps e.g. 0.29 * 100 = 28.999999999999996 at 29
+	return currency.Money{Minor: int64(math.Floor(cfg.PriceEpsilon*scale + 1e-6)), Currency: converter.Base}
+}
+
+func checkAndNotify(ctx context.Context, id string, item TrackingRequest) {
+	if item.Mode == ModeAvailability {
+		checkAvailability(ctx, id, item)
+		return
+	}
+
+	logf(ctx, "Checking price for item %s: %s (target: %s)", id, item.URL, item.TargetPrice.Decimal())
+	scraped, alreadyChecked, err := checkScrape(ctx, item)
+	if alreadyChecked {
+		logf(ctx, "Item %s was already checked with the scrape from %s", id, scraped.fetchedAt.Format(time.RFC3339))
+		return
+	}
+	markChecked(id, err)
+	if err != nil {
+		logf(ctx, "Error checking price for %s: %v", id, err)
+		return
+	}
+	applyPrice(ctx, id, item, scraped.result, scraped.fetchedAt)
+}
+
+// applyPrice records a price observed at observedAt, scraped or pushed in by
+// an external scraper, and sends the alerts it calls for
+func applyPrice(ctx context.Context, id string, item TrackingRequest, result ScrapeResult, observedAt time.Time) {
+	setStockStatus(id, result.InStock)
+	setProductInfo(id, result)
+
+	priceString, currentPrice := result.PriceString, result.Price
+	logf(ctx, "Current price for %s: ₹%s (%s)", id, priceString, currentPrice.Decimal())
+
+	// Compared and alerted after the item's coupon, if any
+	var regularPrice, effectivePrice currency.Money
+	if effective, ok := couponPrice(item, currentPrice); ok {
+		logf(ctx, "Price for %s after its coupon: %s", id, effective.Decimal())
+		regularPrice, effectivePrice, currentPrice = currentPrice, effective, effective
+	}
+
+	code := result.Currency
+	convertedPrice, err := toBaseCurrency(currentPrice, code)
 	if err != nil {
-		log.Printf("Error checking price for %s: %v", id, err)
+		logf(ctx, "Error converting price for %s: %v", id, err)
 		return
 	}
 
-	log.Printf("Current price for %s: ₹%s (%.2f)", id, priceString, currentPrice)
+	history, _, err := store.History(id)
+	if err != nil {
+		logf(ctx, "Failed to load history for %s: %v", id, err)
+	}
+	history = comparableHistory(history, cmp.Or(converter.Base, code))
+	recordPrice(id, PricePoint{
+		Timestamp:      observedAt.Format(time.RFC3339),
+		Price:          cmp.Or(regularPrice, currentPrice),
+		EffectivePrice: effectivePrice,
+		PriceString:    priceString,
+		Currency:       code,
+		ConvertedPrice: convertedPrice,
+		BaseCurrency:   converter.Base,
+	})
 
-	if currentPrice <= item.TargetPrice {
-		log.Printf("Price target reached for %s! Current: %.2f, Target: %.2f", id, currentPrice, item.TargetPrice)
-		alert := PriceAlert{
-			ID:           id,
-			URL:          item.URL,
-			CurrentPrice: currentPrice,
-			TargetPrice:  item.TargetPrice,
-			PriceString:  priceString,
-			Timestamp:    time.Now().Format(time.RFC3339),
+	silent := !inAlertWindow(item, time.Now())
+	minDrop := item.MinDropAmount
+	if minDrop.IsZero() {
+		minDrop = currency.FromFloat(cfg.MinDropAmount, converter.Base)
+	}
+
+	if checkAnomaly(id, history, convertedPrice) {
+		logf(ctx, "Price for %s (%s) is far outside its recent range", id, convertedPrice.Decimal())
+		if cfg.NotifyAnomalies && !silent {
+			deliverAlert(ctx, PriceAlert{
+				ID:              id,
+				URL:             item.URL,
+				Locale:          item.Locale,
+				Title:           cmp.Or(result.Title, item.Title),
+				ImageURL:        cmp.Or(result.ImageURL, item.ImageURL),
+				CurrentPrice:    currentPrice,
+				TargetPrice:     item.TargetPrice,
+				PriceString:     priceString,
+				Currency:        code,
+				ConvertedPrice:  convertedPrice,
+				ListPrice:       result.ListPrice,
+				DiscountPercent: result.DiscountPercent,
+				RegularPrice:    regularPrice,
+				CouponCode:      item.CouponCode,
+				PreviousPrice:   item.LastPrice,
+				BaseCurrency:    converter.Base,
+				Type:            AlertAnomaly,
+				InStock:         result.InStock,
+				Timestamp:       time.Now().Format(time.RFC3339),
+			})
 		}
+	}
 
-		// Send to all connected WebSocket clients
-		mu.RLock()
-		clientCount := len(clients)
-		log.Printf("Sending alert to %d connected clients", clientCount)
-		for client := range clients {
-			select {
-			case client.send <- alert:
-				log.Printf("Alert sent to client successfully")
-			default:
-				log.Printf("Client channel full, closing connection")
-				close(client.send)
-				delete(clients, client)
-			}
+	if item.NotifyOnUptrend {
+		if start := checkUptrend(id, history, convertedPrice); start.Sign() > 0 && !silent {
+			notifyUptrend(ctx, PriceAlert{
+				ID:              id,
+				URL:             item.URL,
+				Locale:          item.Locale,
+				Title:           cmp.Or(result.Title, item.Title),
+				ImageURL:        cmp.Or(result.ImageURL, item.ImageURL),
+				CurrentPrice:    currentPrice,
+				TargetPrice:     alertTarget(item),
+				PriceString:     priceString,
+				Currency:        code,
+				ConvertedPrice:  convertedPrice,
+				ListPrice:       result.ListPrice,
+				DiscountPercent: result.DiscountPercent,
+				RegularPrice:    regularPrice,
+				CouponCode:      item.CouponCode,
+				BaseCurrency:    converter.Base,
+				InStock:         result.InStock,
+				Timestamp:       time.Now().Format(time.RFC3339),
+			}, start)
 		}
-		mu.RUnlock()
+	}
 
-		log.Printf("Price alert sent for %s: ₹%s (target: ₹%.2f)", id, priceString, item.TargetPrice)
+	if low, ok := checkNewLow(ctx, item, history, convertedPrice, minDrop, observedAt); ok && !silent {
+		deliverAlert(ctx, PriceAlert{
+			ID:              id,
+			URL:             item.URL,
+			Locale:          item.Locale,
+			Title:           cmp.Or(result.Title, item.Title),
+			ImageURL:        cmp.Or(result.ImageURL, item.ImageURL),
+			CurrentPrice:    currentPrice,
+			TargetPrice:     alertTarget(item),
+			PriceString:     priceString,
+			Currency:        code,
+			ConvertedPrice:  convertedPrice,
+			ListPrice:       result.ListPrice,
+			DiscountPercent: result.DiscountPercent,
+			RegularPrice:    regularPrice,
+			CouponCode:      item.CouponCode,
+			PreviousPrice:   low,
+			BaseCurrency:    converter.Base,
+			Type:            AlertNewLow,
+			Days:            item.LowestInDays,
+			InStock:         result.InStock,
+			Timestamp:       time.Now().Format(time.RFC3339),
+		})
+	}
 
-		// Stop monitoring this item after sending notification
-		mu.Lock()
-		delete(trackingItems, id)
-		log.Printf("Stopped monitoring item %s after sending notification", id)
-		mu.Unlock()
-	} else {
-		log.Printf("Price not yet at target for %s. Current: %.2f, Target: %.2f", id, currentPrice, item.TargetPrice)
+	if setLastPrice(id, convertedPrice, priceString) {
+		broadcastAlert(PriceAlert{
+			ID:              id,
+			URL:             item.URL,
+			Locale:          item.Locale,
+			Title:           cmp.Or(result.Title, item.Title),
+			ImageURL:        cmp.Or(result.ImageURL, item.ImageURL),
+			CurrentPrice:    currentPrice,
+			TargetPrice:     item.TargetPrice,
+			PriceString:     priceString,
+			Currency:        code,
+			ConvertedPrice:  convertedPrice,
+			ListPrice:       result.ListPrice,
+			DiscountPercent: result.DiscountPercent,
+			RegularPrice:    regularPrice,
+			CouponCode:      item.CouponCode,
+			BaseCurrency:    converter.Base,
+			Type:            AlertBaseline,
+			InStock:         result.InStock,
+			Timestamp:       time.Now().Format(time.RFC3339),
+		})
 	}
+
+	if silent {
+		logf(ctx, "Outside the alert window for %s, not alerting", id)
+		return
+	}
+
+	if len(item.Triggers) > 0 {
+		checkTriggers(ctx, id, PriceAlert{
+			ID:              id,
+			URL:             item.URL,
+			Locale:          item.Locale,
+			Title:           cmp.Or(result.Title, item.Title),
+			ImageURL:        cmp.Or(result.ImageURL, item.ImageURL),
+			CurrentPrice:    currentPrice,
+			PriceString:     priceString,
+			Currency:        code,
+			ConvertedPrice:  convertedPrice,
+			ListPrice:       result.ListPrice,
+			DiscountPercent: result.DiscountPercent,
+			RegularPrice:    regularPrice,
+			CouponCode:      item.CouponCode,
+			PreviousPrice:   item.LastPrice,
+			BaseCurrency:    converter.Base,
+			Type:            AlertPriceDrop,
+			InStock:         result.InStock,
+			Timestamp:       time.Now().Format(time.RFC3339),
+		}, minDrop)
+		return
+	}
+
+	if item.Group != "" {
+		// Its target applies to the group as a whole
+		checkGroupLow(ctx, item, minDrop)
+		return
+	}
+
+	alert := PriceAlert{
+		ID:              id,
+		URL:             item.URL,
+		Locale:          item.Locale,
+		Title:           cmp.Or(result.Title, item.Title),
+		ImageURL:        cmp.Or(result.ImageURL, item.ImageURL),
+		CurrentPrice:    currentPrice,
+		TargetPrice:     item.TargetPrice,
+		PriceString:     priceString,
+		Currency:        code,
+		ConvertedPrice:  convertedPrice,
+		ListPrice:       result.ListPrice,
+		DiscountPercent: result.DiscountPercent,
+		RegularPrice:    regularPrice,
+		CouponCode:      item.CouponCode,
+		PreviousPrice:   item.LastPrice,
+		BaseCurrency:    converter.Base,
+		Type:            AlertPriceDrop,
+		InStock:         result.InStock,
+		Timestamp:       time.Now().Format(time.RFC3339),
+	}
+	target := alertTarget(item)
+	alert.TargetPrice = target
+
+	if continueHold(ctx, id, item, alert) {
+		return
+	}
+
+	if target.Sign() <= 0 || !atOrBelow(convertedPrice, target.Sub(minDrop)) {
+		if item.LastAlertedPrice.Sign() > 0 {
+			// Back above target, so the next drop below it is worth an alert again
+			updateItemState(id, func(current *TrackingRequest) {
+				current.LastAlertedPrice = currency.Money{}
+			})
+		}
+		logf(ctx, "Price not yet at target for %s. Current: %s, Target: %s (min drop %s)", id, convertedPrice.Decimal(), target.Decimal(), minDrop.Decimal())
+		return
+	}
+
+	// Once alerted, only a further drop (of the configured percent, if any) is worth another alert
+	if item.LastAlertedPrice.Sign() > 0 {
+		threshold := currency.Min(item.LastAlertedPrice.Mul(1-item.NotifyFurtherDropPercent/100), item.LastAlertedPrice.Sub(minDrop))
+		if !atOrBelow(convertedPrice, threshold) || atOrBelow(item.LastAlertedPrice, convertedPrice) {
+			logf(ctx, "Price for %s still at target but not far enough below last alert (%s)", id, item.LastAlertedPrice.Decimal())
+			return
+		}
+	}
+	if inAlertCooldown(item, time.Now()) {
+		logf(ctx, "Price for %s dropped further but the last alert was at %s; waiting for the cooldown", id, item.LastAlertedAt)
+		return
+	}
+
+	if startHold(ctx, id, item, alert) {
+		return
+	}
+
+	logf(ctx, "Price target reached for %s! Current: %s, Target: %s", id, convertedPrice.Decimal(), target.Decimal())
+	sendTargetAlert(ctx, id, item, alert)
+}
+
+// sendTargetAlert delivers a price drop alert, then keeps watching the item for a
+// further drop or stops tracking it
+func sendTargetAlert(ctx context.Context, id string, item TrackingRequest, alert PriceAlert) {
+	if deliverAlert(ctx, alert) {
+		logf(ctx, "Price alert sent for %s: ₹%s (target: ₹%s)", id, alert.PriceString, alert.TargetPrice.Decimal())
+	}
+
+	if continueAfterAlert(item) {
+		// Keep watching for a further drop below this alert
+		updateItemState(id, func(current *TrackingRequest) {
+			current.LastAlertedPrice = alert.ConvertedPrice
+			current.LastAlertedAt = time.Now().Format(time.RFC3339)
+		})
+		return
+	}
+
+	// Stop monitoring this item after sending notification
+	stopAfterAlert(item, StopTargetReached)
+}
+
+// continueAfterAlert reports whether an item stays tracked after alerting
+func continueAfterAlert(item TrackingRequest) bool {
+	return item.ContinueAfterAlert == nil || *item.ContinueAfterAlert || item.NotifyFurtherDropPercent > 0
+}
+
+// inAlertCooldown reports whether an item alerted too recently to alert again
+func inAlertCooldown(item TrackingRequest, now time.Time) bool {
+	if cfg.AlertCooldown <= 0 || item.LastAlertedAt == "" {
+		return false
+	}
+	last, err := time.Parse(time.RFC3339, item.LastAlertedAt)
+	return err == nil && now.Sub(last) < cfg.AlertCooldown
+}
+
+// checkAvailability alerts when an item goes from out of stock to in stock
+func checkAvailability(ctx context.Context, id string, item TrackingRequest) {
+	logf(ctx, "Checking availability for item %s: %s", id, item.URL)
+	scraped, alreadyChecked, err := checkScrape(ctx, item)
+	if alreadyChecked {
+		logf(ctx, "Item %s was already checked with the scrape from %s", id, scraped.fetchedAt.Format(time.RFC3339))
+		return
+	}
+	result := scraped.result
+	if errors.Is(err, errPriceNotFound) {
+		err = nil // Stock status is all we need
+	}
+	markChecked(id, err)
+	if err != nil {
+		logf(ctx, "Error checking availability for %s: %v", id, err)
+		return
+	}
+	applyAvailability(ctx, id, item, result)
+}
+
+// applyAvailability records an item's stock status and alerts when it's back in stock
+func applyAvailability(ctx context.Context, id string, item TrackingRequest, result ScrapeResult) {
+	wasInStock := item.InStock
+	setStockStatus(id, result.InStock)
+	setProductInfo(id, result)
+	logf(ctx, "Item %s in stock: %v", id, result.InStock)
+
+	if wasInStock == nil || *wasInStock || !result.InStock {
+		return
+	}
+	if !inAlertWindow(item, time.Now()) {
+		logf(ctx, "Item %s is back in stock outside its alert window, not alerting", id)
+		return
+	}
+
+	logf(ctx, "Item %s is back in stock!", id)
+	alert := PriceAlert{
+		ID:           id,
+		URL:          item.URL,
+		Locale:       item.Locale,
+		Title:        cmp.Or(result.Title, item.Title),
+		ImageURL:     cmp.Or(result.ImageURL, item.ImageURL),
+		CurrentPrice: result.Price,
+		PriceString:  result.PriceString,
+		Type:         AlertBackInStock,
+		InStock:      true,
+		Timestamp:    time.Now().Format(time.RFC3339),
+	}
+	if deliverAlert(ctx, alert) {
+		logf(ctx, "Back-in-stock alert sent for %s", id)
+	}
+
+	// Only a later out-of-stock to in-stock change alerts again, so continuing is spam-free
+	if !continueAfterAlert(item) {
+		stopAfterAlert(item, StopBackInStock)
+	}
+}
+
+func stopAfterAlert(item TrackingRequest, reason string) {
+	deleted, err := store.DeleteItem(item.ID)
+	if err != nil {
+		log.Printf("Failed to stop monitoring item %s: %v", item.ID, err)
+		return
+	}
+	log.Printf("Stopped monitoring item %s after sending notification", item.ID)
+	if deleted {
+		notifyStopped(context.Background(), item, reason)
+	}
+}
+
+// updateItemState records monitor state on a tracked item. Items untracked
+// while a check was running are left alone.
+func updateItemState(id string, apply func(item *TrackingRequest)) {
+	_, err := store.UpdateItem(id, func(item *TrackingRequest) error {
+		apply(item)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errItemNotFound) {
+		log.Printf("Failed to update state for item %s: %v", id, err)
+	}
+}
+
+// markChecked updates a tracked item's check health after a scrape
+func markChecked(id string, err error) {
+	now := time.Now().Format(time.RFC3339)
+	var broken *TrackingRequest
+	updateItemState(id, func(item *TrackingRequest) {
+		item.LastCheckedAt = now
+		if err != nil {
+			item.ConsecutiveFailures++
+			item.LastError = err.Error()
+			item.LastErrorCode = scrapeAPIError(err).Code
+			if needsAttention(*item, err) {
+				item.NeedsAttention = true
+				copied := *item
+				broken = &copied
+			}
+		} else {
+			item.ConsecutiveFailures = 0
+			item.LastError, item.LastErrorCode = "", ""
+			item.LastSuccessAt = now
+			item.NeedsAttention = false
+		}
+	})
+	if broken != nil {
+		notifyNeedsAttention(*broken)
+	}
+}
+
+// setLastPrice stores the latest price, and how it was displayed, on a tracked
+// item and reports whether it was the item's first observed price, which
+// becomes its baseline
+func setLastPrice(id string, price currency.Money, priceString string) bool {
+	first := false
+	updateItemState(id, func(item *TrackingRequest) {
+		first = item.BaselinePrice.IsZero()
+		if first {
+			item.BaselinePrice = price
+		}
+		item.LastPrice, item.LastPriceString = price, priceString
+	})
+	return first
+}
+
+// setProductInfo records the product title, image and list price scraped for an item
+func setProductInfo(id string, result ScrapeResult) {
+	updateItemState(id, func(item *TrackingRequest) {
+		item.Title = cmp.Or(result.Title, item.Title)
+		item.ImageURL = cmp.Or(result.ImageURL, item.ImageURL)
+		item.ListPrice, item.DiscountPercent = result.ListPrice, result.DiscountPercent
+	})
+}
+
+// setStockStatus stores the latest stock status on a tracked item, if it's still tracked
+func setStockStatus(id string, inStock bool) {
+	updateItemState(id, func(item *TrackingRequest) {
+		item.InStock = &inStock
+	})
 }
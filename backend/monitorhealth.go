@@ -0,0 +1,46 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	// When the last monitorPrices tick finished and how many items it checked.
+	// Before the first, lastTickAt is when the monitor started.
+	lastTickAt      = time.Now()
+	lastTickChecked int
+	ticks           int64
+	lastTickMu      sync.Mutex
+)
+
+// recordTick notes a completed monitor tick
+func recordTick(at time.Time, checked int) {
+	lastTickMu.Lock()
+	defer lastTickMu.Unlock()
+	lastTickAt, lastTickChecked = at, checked
+	ticks++
+}
+
+// monitorHealth reports the monitor's last tick for /api/health, and whether it
+// is stuck: no tick has completed for MONITOR_STALE_TICKS intervals, e.g.
+// because the monitor goroutine is wedged or the store can't list items
+func monitorHealth(now time.Time) (status map[string]interface{}, stuck bool) {
+	lastTickMu.Lock()
+	defer lastTickMu.Unlock()
+	staleAfter := time.Duration(max(cfg.MonitorStaleTicks, 1)) * checkInterval
+	stuck = now.Sub(lastTickAt) > staleAfter
+	status = map[string]interface{}{
+		"status":     "running",
+		"interval":   checkInterval.String(),
+		"staleAfter": staleAfter.String(),
+	}
+	if ticks > 0 {
+		status["lastTickAt"] = lastTickAt.UTC().Format(time.RFC3339)
+		status["itemsChecked"] = lastTickChecked
+	}
+	if stuck {
+		status["status"] = "stuck"
+	}
+	return status, stuck
+}
@@ -0,0 +1,125 @@
+// backend/msgpack/msgpack.go
+package msgpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// Marshal encodes v as MessagePack. v is encoded as its JSON form would be, so
+// struct tags and omitempty apply and both encodings carry the same fields.
+// Whole numbers become integers, other numbers 64-bit floats.
+func Marshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := encode(&buf, generic); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encode writes one value decoded from JSON
+func encode(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		return encodeNumber(buf, v)
+	case string:
+		encodeString(buf, v)
+	case []interface{}:
+		writeHeader(buf, len(v), 0x90, 15, 0xdc)
+		for _, elem := range v {
+			if err := encode(buf, elem); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		writeHeader(buf, len(v), 0x80, 15, 0xde)
+		// Sorted like encoding/json, so equal values encode the same
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			encodeString(buf, k)
+			if err := encode(buf, v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unexpected %T", v)
+	}
+	return nil
+}
+
+func encodeNumber(buf *bytes.Buffer, n json.Number) error {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		switch {
+		case i >= 0 && i <= 0x7f:
+			buf.WriteByte(byte(i)) // positive fixint
+		case i < 0 && i >= -32:
+			buf.WriteByte(byte(int8(i))) // negative fixint
+		case i > 0 && i <= math.MaxUint32:
+			buf.WriteByte(0xce) // uint 32
+			buf.Write(binary.BigEndian.AppendUint32(nil, uint32(i)))
+		default:
+			buf.WriteByte(0xd3) // int 64
+			buf.Write(binary.BigEndian.AppendUint64(nil, uint64(i)))
+		}
+		return nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return fmt.Errorf("msgpack: invalid number %q", n)
+	}
+	buf.WriteByte(0xcb) // float 64
+	buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+	return nil
+}
+
+func encodeString(buf *bytes.Buffer, s string) {
+	if len(s) > 31 && len(s) <= math.MaxUint8 {
+		buf.Write([]byte{0xd9, byte(len(s))}) // str 8
+	} else {
+		writeHeader(buf, len(s), 0xa0, 31, 0xda)
+	}
+	buf.WriteString(s)
+}
+
+// writeHeader writes the length of a string, array or map: in the fix
+// format's low bits up to fixMax, otherwise after the 16-bit format's marker,
+// or the 32-bit format's that follows it
+func writeHeader(buf *bytes.Buffer, n int, fix byte, fixMax int, marker16 byte) {
+	switch {
+	case n <= fixMax:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(marker16)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		buf.WriteByte(marker16 + 1)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
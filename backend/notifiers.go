@@ -0,0 +1,168 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"price-tracker-backend/currency"
+	"price-tracker-backend/notify"
+)
+
+var notifiers = configuredNotifiers()
+
+// configuredNotifiers builds a notifier for every channel that has settings
+func configuredNotifiers() []notify.Notifier {
+	client := notify.NewHTTPClient(cfg.NotifyTimeout, cfg.NotifyRetries, cfg.NotifyRetryBackoff, cfg.NotifyMaxRetryWait)
+	var list []notify.Notifier
+	if cfg.WebhookURL != "" {
+		list = append(list, notify.NewWebhook(cfg.WebhookURL, client))
+	}
+	if cfg.TelegramBotToken != "" && cfg.TelegramChatID != "" {
+		list = append(list, notify.NewTelegram(cfg.TelegramBotToken, cfg.TelegramChatID, client))
+	}
+	if cfg.SMTPHost != "" && len(cfg.SMTPTo) > 0 {
+		list = append(list, &notify.SMTP{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+			To:       cfg.SMTPTo,
+		})
+	}
+	return list
+}
+
+// alertTemplates render alerts for the notifier channels, one per bundled locale.
+// Custom templates replace them for every locale. They're checked at startup.
+var alertTemplates = mustAlertTemplates()
+
+func mustAlertTemplates() map[string]*notify.Template {
+	if _, ok := notify.NormalizeLocale(cfg.NotifyLocale); !ok {
+		log.Fatalf("Unsupported NOTIFY_LOCALE %q, expected one of %v", cfg.NotifyLocale, notify.Locales())
+	}
+	templates := map[string]*notify.Template{}
+	for _, locale := range notify.Locales() {
+		var t *notify.Template
+		var err error
+		if cfg.NotifyTitleTemplate != "" || cfg.NotifyBodyTemplate != "" {
+			t, err = notify.ParseTemplate(cfg.NotifyTitleTemplate, cfg.NotifyBodyTemplate)
+		} else {
+			t, err = notify.LocaleTemplate(locale)
+		}
+		if err != nil {
+			log.Fatalf("Invalid notification template: %v", err)
+		}
+		templates[locale] = t
+	}
+	return templates
+}
+
+// alertLocale is the bundled locale an alert is written in
+func alertLocale(alert PriceAlert) string {
+	locale, _ := notify.NormalizeLocale(cmp.Or(alert.Locale, cfg.NotifyLocale))
+	return locale
+}
+
+// alertMessage renders a price alert for the notifier channels
+func alertMessage(alert PriceAlert) notify.Message {
+	locale := alertLocale(alert)
+	data := notify.AlertData{
+		Type:           alert.Type,
+		Title:          alert.Title,
+		URL:            alert.URL,
+		ImageURL:       alert.ImageURL,
+		CurrentPrice:   alert.ConvertedPrice.Float(),
+		PriceString:    alert.PriceString,
+		FormattedPrice: alert.FormattedPrice,
+		Currency:       alert.Currency,
+		PreviousPrice:  alert.PreviousPrice.Float(),
+		TargetPrice:    alert.TargetPrice.Float(),
+
+		FormattedTargetPrice: currency.FormatLocale(alert.TargetPrice.Float(), cmp.Or(alert.BaseCurrency, alert.Currency), locale),
+
+		ListPrice:       alert.ListPrice.Float(),
+		DiscountPercent: alert.DiscountPercent,
+		Days:            alert.Days,
+		Group:           alert.Group,
+		Retailer:        alert.Retailer,
+		CouponCode:      alert.CouponCode,
+	}
+	if alert.RegularPrice.Sign() > 0 {
+		data.FormattedRegularPrice = currency.FormatLocale(alert.RegularPrice.Float(), alert.Currency, locale)
+	}
+	if alert.ListPrice.Sign() > 0 {
+		data.FormattedListPrice = currency.FormatLocale(alert.ListPrice.Float(), alert.Currency, locale)
+	}
+	if alert.CurrentPrice.Sign() > 0 {
+		data.FormattedPrice = currency.FormatLocale(alert.CurrentPrice.Float(), alert.Currency, locale)
+	}
+	if data.Title == "" {
+		data.Title = alert.URL
+	}
+	if alert.PreviousPrice.Sign() > 0 && alert.ConvertedPrice.Sign() > 0 {
+		data.PercentChange = currency.PercentChange(alert.PreviousPrice, alert.ConvertedPrice)
+	}
+
+	msg, err := alertTemplates[locale].Render(data)
+	if err != nil {
+		log.Printf("Notification template failed for %s, sending the default message: %v", alert.ID, err)
+		fallback, _ := notify.ParseTemplate("", "")
+		msg, _ = fallback.Render(data)
+	}
+	return msg
+}
+
+// notifyBudget bounds one round of notifications: every attempt of a request
+// and the waits between them
+func notifyBudget() time.Duration {
+	retries := time.Duration(max(cfg.NotifyRetries, 0))
+	return (retries+1)*cfg.NotifyTimeout + retries*cfg.NotifyMaxRetryWait
+}
+
+// notifyChannels sends an alert through every configured notifier it's meant for
+func notifyChannels(ctx context.Context, alert PriceAlert) {
+	var targets []notify.Notifier
+	for _, n := range notifiers {
+		if wantsChannel(alert, n.Name()) {
+			targets = append(targets, n)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+	logf(ctx, "Sending alert for %s to %d notifiers", alert.ID, len(targets))
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notifyBudget())
+	defer cancel()
+	notify.SendAll(ctx, targets, alertMessage(alert))
+}
+
+// testNotifiers sends a test message through each configured notifier and reports
+// the outcome per channel. It returns false if any channel failed.
+func testNotifiers() bool {
+	if len(notifiers) == 0 {
+		fmt.Println("No notifiers configured")
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyBudget())
+	defer cancel()
+	results := notify.SendAll(ctx, notifiers, notify.Message{
+		Title: "Price Tracker test notification",
+		Body:  "If you can read this, notifications are configured correctly.",
+	})
+
+	ok := true
+	for _, r := range results {
+		if r.Err != nil {
+			ok = false
+			fmt.Printf("  %-10s FAILED: %v\n", r.Notifier, r.Err)
+		} else {
+			fmt.Printf("  %-10s OK\n", r.Notifier)
+		}
+	}
+	return ok
+}
@@ -0,0 +1,104 @@
+// backend/notify/http.go
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// HTTPClient sends the requests of HTTP-based notifiers, retrying those that
+// may work later: network errors, 429 and 5xx responses. Other 4xx responses
+// are returned as they are, since sending again won't change them.
+type HTTPClient struct {
+	Client     *http.Client  // Its Timeout bounds each attempt
+	Retries    int           // Attempts after the first
+	Backoff    time.Duration // Wait before the first retry, doubled for each next one
+	MaxBackoff time.Duration // Longest wait; a longer Retry-After gives up instead
+}
+
+// NewHTTPClient returns a client with a per-attempt timeout and retries
+func NewHTTPClient(timeout time.Duration, retries int, backoff, maxBackoff time.Duration) *HTTPClient {
+	return &HTTPClient{Client: &http.Client{Timeout: timeout}, Retries: retries, Backoff: backoff, MaxBackoff: maxBackoff}
+}
+
+// retryable reports whether a response is worth another try
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryAfter reads a Retry-After header given in seconds or as an HTTP date
+func retryAfter(res *http.Response, now time.Time) (time.Duration, bool) {
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// Do sends req, retrying as described on HTTPClient until ctx is done. Only
+// requests whose body can be replayed are retried, which they can when made by
+// http.NewRequest from a bytes or strings reader.
+func (c *HTTPClient) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	wait, retries := c.Backoff, c.Retries
+	if req.Body != nil && req.GetBody == nil {
+		retries = 0 // The body can't be sent again
+	}
+	for attempt := 0; ; attempt++ {
+		try := req.Clone(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			try.Body = body
+		}
+
+		res, err := c.Client.Do(try)
+		if attempt >= retries || ctx.Err() != nil || (err == nil && !retryable(res.StatusCode)) {
+			return res, err
+		}
+
+		delay, reason := wait, ""
+		if err != nil {
+			reason = err.Error()
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				reason = urlErr.Err.Error() // The URL may hold a secret, e.g. a bot token
+			}
+		} else {
+			reason = res.Status
+			if after, ok := retryAfter(res, time.Now()); ok {
+				if after > c.MaxBackoff {
+					return res, nil // Honor the server rather than retrying early
+				}
+				delay = max(delay, after)
+			}
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+		delay = min(delay, c.MaxBackoff)
+		log.Printf("Notification request to %s failed (%s), retrying in %s", req.URL.Host, reason, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("gave up retrying after %s: %w", reason, ctx.Err())
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
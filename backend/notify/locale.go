@@ -0,0 +1,87 @@
+// backend/notify/locale.go
+package notify
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultLocale is used for items without a locale and for unknown ones
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// bundle holds one language's title and body templates per alert type. The
+// "price_drop" entries are also used for types a bundle doesn't list.
+type bundle struct {
+	Titles map[string]string `json:"titles"`
+	Bodies map[string]string `json:"bodies"`
+}
+
+// Locales lists the bundled notification languages, e.g. ["de", "en"]
+func Locales() []string {
+	entries, _ := localeFiles.ReadDir("locales")
+	var list []string
+	for _, e := range entries {
+		list = append(list, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	sort.Strings(list)
+	return list
+}
+
+// NormalizeLocale maps a language tag such as "de-DE" or "DE" to a bundled
+// locale, reporting false if there is no bundle for it
+func NormalizeLocale(locale string) (string, bool) {
+	lang, _, _ := strings.Cut(strings.ToLower(strings.ReplaceAll(locale, "_", "-")), "-")
+	if _, err := localeFiles.ReadFile(path.Join("locales", lang+".json")); err != nil {
+		return DefaultLocale, false
+	}
+	return lang, true
+}
+
+// LocaleTemplate builds the notification template for a bundled locale
+func LocaleTemplate(locale string) (*Template, error) {
+	data, err := localeFiles.ReadFile(path.Join("locales", locale+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("no message bundle for locale %q", locale)
+	}
+	var b bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("invalid message bundle %s: %w", locale, err)
+	}
+	return ParseTemplate(switchOnType(b.Titles), switchOnType(b.Bodies))
+}
+
+// switchOnType turns per-type templates into one template branching on .Type
+func switchOnType(byType map[string]string) string {
+	types := make([]string, 0, len(byType))
+	for t := range byType {
+		if t != "price_drop" {
+			types = append(types, t)
+		}
+	}
+	sort.Strings(types)
+
+	var sb strings.Builder
+	for i, t := range types {
+		if i == 0 {
+			sb.WriteString("{{if eq .Type " + fmt.Sprintf("%q", t) + "}}")
+		} else {
+			sb.WriteString("{{else if eq .Type " + fmt.Sprintf("%q", t) + "}}")
+		}
+		sb.WriteString(byType[t])
+	}
+	if len(types) > 0 {
+		sb.WriteString("{{else}}")
+	}
+	sb.WriteString(byType["price_drop"])
+	if len(types) > 0 {
+		sb.WriteString("{{end}}")
+	}
+	return sb.String()
+}
@@ -0,0 +1,50 @@
+// backend/notify/notify.go
+package notify
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// Message is a channel-independent notification
+type Message struct {
+	Title string
+	Body  string
+	URL   string // Product page to open
+	Image string // Product image URL, may be empty
+	// Informational messages that shouldn't interrupt, e.g. tracker lifecycle events
+	LowPriority bool
+}
+
+// Notifier delivers messages over one channel (email, chat, webhook, ...)
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
+
+// Result is the outcome of sending to one notifier
+type Result struct {
+	Notifier string
+	Err      error
+}
+
+// SendAll delivers msg through every notifier at once, so one that's slow or
+// retrying doesn't hold up the others, and reports each outcome in order
+func SendAll(ctx context.Context, notifiers []Notifier, msg Message) []Result {
+	results := make([]Result, len(notifiers))
+	var wg sync.WaitGroup
+	for i, n := range notifiers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := n.Send(ctx, msg)
+			if err != nil {
+				log.Printf("Notifier %s failed: %v", n.Name(), err)
+			}
+			results[i] = Result{Notifier: n.Name(), Err: err}
+		}()
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,59 @@
+// backend/notify/smtp.go
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// SMTP sends messages as plain-text email
+type SMTP struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+func (s *SMTP) Name() string { return "smtp" }
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	body := msg.Body
+	if msg.URL != "" {
+		body += "\r\n\r\n" + msg.URL
+	}
+	headers := []string{
+		"From: " + s.From,
+		"To: " + strings.Join(s.To, ", "),
+		"Subject: " + msg.Title,
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+	if msg.LowPriority {
+		headers = append(headers, "X-Priority: 5", "Importance: low")
+	}
+	email := strings.Join(append(headers, "", body), "\r\n")
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	// net/smtp has no context support, so run the send and give up when ctx ends
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(net.JoinHostPort(s.Host, s.Port), auth, s.From, s.To, []byte(email))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("smtp send failed: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("smtp send failed: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,53 @@
+// backend/notify/telegram.go
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Telegram sends messages through a bot to a chat
+type Telegram struct {
+	BotToken string
+	ChatID   string
+	Client   *HTTPClient
+}
+
+func NewTelegram(botToken, chatID string, client *HTTPClient) *Telegram {
+	return &Telegram{BotToken: botToken, ChatID: chatID, Client: client}
+}
+
+func (t *Telegram) Name() string { return "telegram" }
+
+func (t *Telegram) Send(ctx context.Context, msg Message) error {
+	text := msg.Title + "\n" + msg.Body
+	if msg.URL != "" {
+		text += "\n" + msg.URL
+	}
+	form := url.Values{"chat_id": {t.ChatID}, "text": {text}}
+	if msg.LowPriority {
+		form.Set("disable_notification", "true") // Delivered silently
+	}
+
+	endpoint := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", t.BotToken)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to build telegram request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := t.Client.Do(ctx, req)
+	if err != nil {
+		// The error message contains the bot token via the URL; don't leak it into logs
+		return fmt.Errorf("telegram request failed: %v", strings.ReplaceAll(err.Error(), t.BotToken, "***"))
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("telegram returned %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+// backend/notify/template.go
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// AlertData is what notification templates can refer to, e.g. {{.Title}} or {{.PercentChange}}
+type AlertData struct {
+	Type           string // "price_drop", "back_in_stock", "expired", "anomaly", "needs_attention", "price_uptrend", "new_low", "group_low", ...
+	Title          string // Product name, or the URL when it's unknown
+	URL            string
+	ImageURL       string
+	CurrentPrice   float64 // Price in the base currency, or as scraped without one
+	PriceString    string  // Price as it appeared on the page
+	FormattedPrice string
+	Currency       string
+	PreviousPrice  float64 // Price at the previous check, 0 if unknown
+	PercentChange  float64 // Change from PreviousPrice in percent, negative for drops
+	TargetPrice    float64
+	// TargetPrice written with its currency, e.g. "₹1,299.00"
+	FormattedTargetPrice string
+	// Struck-through list price on the page, 0 if none, and how far below it
+	// the price is in percent
+	ListPrice          float64
+	FormattedListPrice string
+	DiscountPercent    float64
+	Days               int // Window of a "new_low" alert: the price is the lowest in this many days
+	// Product group of a "group_low" alert and the site of its cheapest member
+	Group    string
+	Retailer string
+	// Price on the page before the item's coupon when FormattedPrice is after
+	// it, empty otherwise, and the coupon's code if known
+	FormattedRegularPrice string
+	CouponCode            string
+}
+
+// Default templates, matching the built-in wording
+const (
+	DefaultTitleTemplate = `{{if eq .Type "back_in_stock"}}Back in stock!{{else if eq .Type "expired"}}Tracking expired{{else if eq .Type "anomaly"}}Unusual price{{else if eq .Type "needs_attention"}}Needs attention{{else if eq .Type "price_uptrend"}}Price rising{{else if eq .Type "new_low"}}New low!{{else if eq .Type "group_low"}}Best price across retailers{{else}}Price Alert!{{end}}`
+	DefaultBodyTemplate  = `{{if eq .Type "back_in_stock"}}{{.Title}} is available again{{else if eq .Type "expired"}}Tracking for {{.Title}} expired and it is no longer being watched{{else if eq .Type "anomaly"}}{{.Title}} is at {{printf "%.2f" .CurrentPrice}}, far outside its recent range. It may be a great deal or a scraping error.{{else if eq .Type "needs_attention"}}No price was found for {{.Title}} in the last checks, though it was found before. The site may have changed.{{else if eq .Type "price_uptrend"}}{{.Title}} has risen {{printf "%.0f" .PercentChange}}% over its last checks, to {{printf "%.2f" .CurrentPrice}}. Buy soon if you want it before it climbs further.{{else if eq .Type "new_low"}}{{.Title}} is at {{printf "%.2f" .CurrentPrice}}, its lowest price in {{.Days}} days (previous low: {{printf "%.2f" .PreviousPrice}}){{else if eq .Type "group_low"}}{{.Title}} is cheapest at {{.Retailer}}: {{printf "%.2f" .CurrentPrice}} (target: {{printf "%.2f" .TargetPrice}}){{else}}{{.Title}} dropped to {{printf "%.2f" .CurrentPrice}}{{if .FormattedRegularPrice}} after coupon{{with .CouponCode}} {{.}}{{end}}{{end}} (target: {{printf "%.2f" .TargetPrice}}){{if ge .DiscountPercent 1.0}}, {{printf "%.0f" .DiscountPercent}}% off list!{{end}}{{end}}`
+)
+
+// Template renders alerts into messages
+type Template struct {
+	title *template.Template
+	body  *template.Template
+}
+
+// ParseTemplate parses title and body templates, using the defaults for empty
+// strings. Both are test-rendered so unknown fields are reported up front.
+func ParseTemplate(title, body string) (*Template, error) {
+	if title == "" {
+		title = DefaultTitleTemplate
+	}
+	if body == "" {
+		body = DefaultBodyTemplate
+	}
+	t := &Template{}
+	var err error
+	if t.title, err = template.New("title").Option("missingkey=error").Parse(title); err != nil {
+		return nil, fmt.Errorf("invalid title template: %w", err)
+	}
+	if t.body, err = template.New("body").Option("missingkey=error").Parse(body); err != nil {
+		return nil, fmt.Errorf("invalid body template: %w", err)
+	}
+	if _, err := t.Render(AlertData{Type: "price_drop"}); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Render builds the message for an alert
+func (t *Template) Render(data AlertData) (Message, error) {
+	var title, body bytes.Buffer
+	if err := t.title.Execute(&title, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render title template: %w", err)
+	}
+	if err := t.body.Execute(&body, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render body template: %w", err)
+	}
+	return Message{
+		Title: strings.TrimSpace(title.String()),
+		Body:  strings.TrimSpace(body.String()),
+		URL:   data.URL,
+		Image: data.ImageURL,
+	}, nil
+}
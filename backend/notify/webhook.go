@@ -0,0 +1,88 @@
+// backend/notify/webhook.go
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Webhook POSTs messages as JSON to a URL. Discord webhook URLs get Discord's
+// embed format, with the product image as the thumbnail.
+type Webhook struct {
+	URL    string
+	Client *HTTPClient
+}
+
+func NewWebhook(url string, client *HTTPClient) *Webhook {
+	return &Webhook{URL: url, Client: client}
+}
+
+func (w *Webhook) Name() string { return "webhook" }
+
+func (w *Webhook) Send(ctx context.Context, msg Message) error {
+	priority := "normal"
+	if msg.LowPriority {
+		priority = "low"
+	}
+	var body interface{} = map[string]string{
+		"title":    msg.Title,
+		"body":     msg.Body,
+		"url":      msg.URL,
+		"image":    msg.Image,
+		"priority": priority,
+	}
+	if isDiscordWebhook(w.URL) {
+		body = discordPayload(msg)
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := w.Client.Do(ctx, req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", res.Status)
+	}
+	return nil
+}
+
+func isDiscordWebhook(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	return (host == "discord.com" || host == "discordapp.com" || strings.HasSuffix(host, ".discord.com")) &&
+		strings.HasPrefix(u.Path, "/api/webhooks/")
+}
+
+// discordPayload renders a message as a Discord embed
+func discordPayload(msg Message) map[string]interface{} {
+	embed := map[string]interface{}{
+		"title":       msg.Title,
+		"description": msg.Body,
+	}
+	if msg.URL != "" {
+		embed["url"] = msg.URL
+	}
+	if msg.Image != "" {
+		embed["thumbnail"] = map[string]string{"url": msg.Image}
+	}
+	return map[string]interface{}{"embeds": []interface{}{embed}}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page sizes for GET /api/tracked-items
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// itemCursor is the sort key of the last item on a page. Items sort by when
+// they were tracked, then by ID, so a cursor stays valid while items are
+// added or removed.
+type itemCursor struct {
+	createdAt time.Time
+	id        string
+}
+
+func cursorOf(item TrackingRequest) itemCursor {
+	return itemCursor{createdAt: itemCreatedAt(item), id: item.ID}
+}
+
+// itemCreatedAt parses an item's CreatedAt; items tracked before it was
+// recorded sort first
+func itemCreatedAt(item TrackingRequest) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, item.CreatedAt)
+	return t
+}
+
+func (c itemCursor) compare(other itemCursor) int {
+	if n := c.createdAt.Compare(other.createdAt); n != 0 {
+		return n
+	}
+	return strings.Compare(c.id, other.id)
+}
+
+// encode makes the cursor opaque to clients
+func (c itemCursor) encode() string {
+	raw := ":" + c.id
+	if !c.createdAt.IsZero() {
+		raw = strconv.FormatInt(c.createdAt.UnixNano(), 10) + raw
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+func decodeCursor(s string) (itemCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return itemCursor{}, errInvalidCursor
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok || !validID.MatchString(id) {
+		return itemCursor{}, errInvalidCursor
+	}
+	c := itemCursor{id: id}
+	if nanos != "" {
+		n, err := strconv.ParseInt(nanos, 10, 64)
+		if err != nil {
+			return itemCursor{}, errInvalidCursor
+		}
+		c.createdAt = time.Unix(0, n)
+	}
+	return c, nil
+}
+
+// sortItems orders items by creation, oldest first, with the ID breaking ties
+func sortItems(items []TrackingRequest) {
+	slices.SortFunc(items, func(a, b TrackingRequest) int {
+		return cursorOf(a).compare(cursorOf(b))
+	})
+}
+
+// parsePageSize reads the limit query parameter
+func parsePageSize(s string) (int, error) {
+	if s == "" {
+		return defaultPageSize, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > maxPageSize {
+		return 0, fmt.Errorf("limit must be a number from 1 to %d", maxPageSize)
+	}
+	return n, nil
+}
+
+// pageItems returns the sorted items after the cursor (from the start when
+// it's empty), at most limit of them, and the cursor of the next page, which
+// is "" on the last page
+func pageItems(items []TrackingRequest, cursor string, limit int) ([]TrackingRequest, string, error) {
+	start := 0
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		start, _ = slices.BinarySearchFunc(items, after, func(item TrackingRequest, c itemCursor) int {
+			// The cursor's own item (if still tracked) was on the previous page
+			if cursorOf(item).compare(c) <= 0 {
+				return -1
+			}
+			return 1
+		})
+	}
+	end := min(start+limit, len(items))
+	page := items[start:end]
+	if end == len(items) {
+		return page, "", nil
+	}
+	return page, cursorOf(page[len(page)-1]).encode(), nil
+}
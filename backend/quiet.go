@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"price-tracker-backend/currency"
+)
+
+// pendingAlert is an alert held back during quiet hours
+type pendingAlert struct {
+	Alert    PriceAlert
+	QueuedAt time.Time
+}
+
+var (
+	pendingAlerts []pendingAlert
+	pendingMu     sync.Mutex
+)
+
+// parseClock turns "HH:MM" into minutes since midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// inQuietHours reports whether now falls inside the configured quiet-hours window.
+// Windows that wrap past midnight (e.g. 22:00-07:00) are supported.
+func inQuietHours(now time.Time) bool {
+	if cfg.QuietHoursStart == "" || cfg.QuietHoursEnd == "" {
+		return false
+	}
+	start, err := parseClock(cfg.QuietHoursStart)
+	if err != nil {
+		log.Printf("Quiet hours disabled: %v", err)
+		return false
+	}
+	end, err := parseClock(cfg.QuietHoursEnd)
+	if err != nil {
+		log.Printf("Quiet hours disabled: %v", err)
+		return false
+	}
+
+	local := now.In(cfg.QuietHoursLocation)
+	minutes := local.Hour()*60 + local.Minute()
+	if start <= end {
+		return minutes >= start && minutes < end
+	}
+	return minutes >= start || minutes < end
+}
+
+// isUrgent reports whether an alert's drop below target is large enough to bypass quiet hours
+func isUrgent(alert PriceAlert) bool {
+	if cfg.UrgentDropPercent <= 0 || alert.TargetPrice.Sign() <= 0 {
+		return false
+	}
+	drop := -currency.PercentChange(alert.TargetPrice, alert.ConvertedPrice)
+	return drop >= cfg.UrgentDropPercent
+}
+
+// deliverAlert broadcasts an alert, or queues it if quiet hours are in effect.
+// It returns true if the alert was sent right away. Repeats of an alert already
+// sent are dropped.
+func deliverAlert(ctx context.Context, alert PriceAlert) bool {
+	if isDuplicateAlert(alert, time.Now()) {
+		logf(ctx, "Suppressed duplicate %s alert for %s at %s", alert.Type, alert.ID, alert.ConvertedPrice.Decimal())
+		return false
+	}
+	if inQuietHours(time.Now()) && !isUrgent(alert) {
+		pendingMu.Lock()
+		pendingAlerts = append(pendingAlerts, pendingAlert{Alert: alert, QueuedAt: time.Now()})
+		pendingMu.Unlock()
+		logf(ctx, "Quiet hours: queued alert for %s until %s", alert.ID, cfg.QuietHoursEnd)
+		return false
+	}
+	if wantsChannel(alert, ChannelDashboard) {
+		broadcastAlert(alert)
+	}
+	go notifyChannels(ctx, alert)
+	return true
+}
+
+// flushPendingAlerts delivers queued alerts once the quiet-hours window has ended
+func flushPendingAlerts() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if inQuietHours(time.Now()) {
+			continue
+		}
+
+		pendingMu.Lock()
+		queued := pendingAlerts
+		pendingAlerts = nil
+		pendingMu.Unlock()
+
+		for _, p := range queued {
+			log.Printf("Delivering alert for %s queued at %s", p.Alert.ID, p.QueuedAt.Format(time.RFC3339))
+			if wantsChannel(p.Alert, ChannelDashboard) {
+				broadcastAlert(p.Alert)
+			}
+			notifyChannels(context.Background(), p.Alert)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/time/rate"
+)
+
+// rateLimit is a token bucket: requests refill at Every, up to Burst at once
+type rateLimit struct {
+	Every time.Duration
+	Burst int
+}
+
+// Limits for routes that scrape on every request, unless RATE_LIMITS overrides
+// them. Other routes get RATE_LIMIT_DEFAULT.
+var defaultRouteLimits = map[string]string{
+	"/api/check-price":              "10/m",
+	"/api/track-price":              "30/m", // Scrapes the new item right away
+	"/api/tracked-items/{id}/check": "10/m",
+	"/api/diagnostics/{id}":         "5/m",
+	"/api/health":                   "0", // Polled by monitoring
+}
+
+// parseRateLimit parses "N/unit" with unit s, m or h, e.g. "10/m" for ten
+// requests a minute with bursts of ten. "0" turns limiting off (nil).
+func parseRateLimit(s string) (*rateLimit, error) {
+	s = strings.TrimSpace(s)
+	if s == "0" || s == "" {
+		return nil, nil
+	}
+	count, unit, ok := strings.Cut(s, "/")
+	n, err := strconv.Atoi(count)
+	if !ok || err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid rate limit %q, expected e.g. \"10/m\"", s)
+	}
+	per := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}[unit]
+	if per == 0 {
+		return nil, fmt.Errorf("invalid rate limit unit %q in %q, expected s, m or h", unit, s)
+	}
+	return &rateLimit{Every: per / time.Duration(n), Burst: n}, nil
+}
+
+// loadRouteLimits merges RATE_LIMITS ("/api/check-price=5/m,...") into the defaults
+func loadRouteLimits() (map[string]*rateLimit, *rateLimit) {
+	routes := make(map[string]*rateLimit)
+	specs := make(map[string]string, len(defaultRouteLimits))
+	for route, spec := range defaultRouteLimits {
+		specs[route] = spec
+	}
+	for _, pair := range cfg.RateLimits {
+		route, spec, ok := strings.Cut(pair, "=")
+		if !ok {
+			log.Printf("Ignoring invalid entry %q in RATE_LIMITS, expected route=N/unit", pair)
+			continue
+		}
+		specs[strings.TrimSpace(route)] = spec
+	}
+	for route, spec := range specs {
+		limit, err := parseRateLimit(spec)
+		if err != nil {
+			log.Printf("Ignoring rate limit for %s: %v", route, err)
+			continue
+		}
+		routes[route] = limit
+	}
+	fallback, err := parseRateLimit(cfg.RateLimitDefault)
+	if err != nil {
+		log.Printf("Ignoring RATE_LIMIT_DEFAULT: %v", err)
+	}
+	return routes, fallback
+}
+
+var routeLimits, defaultRouteLimit = loadRouteLimits()
+
+// limitFor returns the limit for a route template, nil if it's unlimited
+func limitFor(route string) *rateLimit {
+	if limit, ok := routeLimits[route]; ok {
+		return limit
+	}
+	return defaultRouteLimit
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	limit    rateLimit
+	lastSeen time.Time
+}
+
+// refilled reports whether the bucket has been idle long enough to be full
+// again, so forgetting it changes nothing
+func (cl *clientLimiter) refilled(now time.Time) bool {
+	return now.Sub(cl.lastSeen) >= cl.limit.Every*time.Duration(cl.limit.Burst)
+}
+
+// Token buckets per route and client IP
+var (
+	clientLimiters   = make(map[string]*clientLimiter)
+	clientLimitersMu sync.Mutex
+	nextLimiterSweep time.Time
+)
+
+// reserve takes a token from the client's bucket for the route, returning how
+// long the client must wait first if the bucket is empty
+func reserve(route, client string, limit *rateLimit, now time.Time) time.Duration {
+	clientLimitersMu.Lock()
+	defer clientLimitersMu.Unlock()
+	if now.After(nextLimiterSweep) {
+		for key, cl := range clientLimiters {
+			if cl.refilled(now) {
+				delete(clientLimiters, key)
+			}
+		}
+		nextLimiterSweep = now.Add(time.Minute)
+	}
+	key := route + " " + client
+	cl, ok := clientLimiters[key]
+	if !ok {
+		cl = &clientLimiter{limiter: rate.NewLimiter(rate.Every(limit.Every), limit.Burst), limit: *limit}
+		clientLimiters[key] = cl
+	}
+	cl.lastSeen = now
+	res := cl.limiter.ReserveN(now, 1)
+	if delay := res.DelayFrom(now); delay > 0 {
+		res.CancelAt(now)
+		return delay
+	}
+	return 0
+}
+
+// clientIP identifies the client for rate limiting: the connection's address,
+// or behind trusted proxies the X-Forwarded-For address the outermost of them
+// added. Entries further left are written by the client and could be changed
+// on every request to get a fresh bucket.
+func clientIP(r *http.Request) string {
+	if cfg.RateLimitTrustProxy {
+		var forwarded []string
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			for _, addr := range strings.Split(header, ",") {
+				if addr = strings.TrimSpace(addr); addr != "" {
+					forwarded = append(forwarded, addr)
+				}
+			}
+		}
+		if hops := max(cfg.RateLimitProxyHops, 1); len(forwarded) >= hops {
+			return forwarded[len(forwarded)-hops]
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// withRateLimit answers 429 with Retry-After once a client exceeds its route's limit
+func withRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if tmpl, err := current.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+		limit := limitFor(route)
+		if limit == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if wait := reserve(route, clientIP(r), limit, time.Now()); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			w.Header().Set("Content-Type", "application/json")
+			writeError(w, http.StatusTooManyRequests, ErrCodeRateLimited, fmt.Sprintf("Too many requests to %s; try again in %s", route, wait.Round(time.Second)))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// decodeJSON reads a size- and time-limited JSON body into dst, rejecting unknown
+// fields. On failure it writes the error response and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if cfg.RequestReadTimeout > 0 {
+		// Not every ResponseWriter supports deadlines; the size limit still applies
+		if err := http.NewResponseController(w).SetReadDeadline(time.Now().Add(cfg.RequestReadTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			log.Printf("Failed to set read deadline: %v", err)
+		}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxRequestBodyBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(dst)
+	if err == nil {
+		// A second value in the body is as malformed as a broken first one
+		if dec.Decode(&struct{}{}) != io.EOF {
+			err = errors.New("body must contain a single JSON object")
+		}
+	}
+	if err == nil {
+		return true
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeError(w, http.StatusRequestEntityTooLarge, ErrCodeBodyTooLarge, fmt.Sprintf("Request body too large (limit %d bytes)", maxBytesErr.Limit))
+		return false
+	}
+	if field, ok := unknownField(err); ok {
+		msg := fmt.Sprintf("Unknown field %q in request body", field)
+		if known := matchField(dst, field); known != "" {
+			msg += fmt.Sprintf(" (did you mean %q?)", known)
+		}
+		writeError(w, http.StatusBadRequest, ErrCodeUnknownField, msg)
+		return false
+	}
+	writeError(w, http.StatusBadRequest, ErrCodeInvalidBody, fmt.Sprintf("Invalid request body: %v", err))
+	return false
+}
+
+// unknownField extracts the field name from the error DisallowUnknownFields produces.
+// encoding/json has no typed error for this, so the message is matched instead.
+func unknownField(err error) (string, bool) {
+	const prefix = "json: unknown field "
+	msg := err.Error()
+	if !strings.HasPrefix(msg, prefix) {
+		return "", false
+	}
+	field, uerr := strconv.Unquote(strings.TrimPrefix(msg, prefix))
+	if uerr != nil {
+		return "", false
+	}
+	return field, true
+}
+
+var fieldKey = strings.NewReplacer("_", "", "-", "", " ", "")
+
+// matchField finds a JSON field of dst that differs from name only in case or
+// separators, e.g. "target_price" for "targetPrice". encoding/json already
+// matches names case-insensitively, so separators are what usually goes wrong.
+func matchField(dst interface{}, name string) string {
+	t := reflect.TypeOf(dst)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return ""
+	}
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if tag == "" {
+			tag = t.Field(i).Name
+		}
+		if strings.EqualFold(fieldKey.Replace(tag), fieldKey.Replace(name)) {
+			return tag
+		}
+	}
+	return ""
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/temoto/robotstxt"
+
+	"price-tracker-backend/scraper"
+)
+
+// ROBOTS_TXT modes
+const (
+	RobotsIgnore  = "ignore"  // Don't fetch robots.txt
+	RobotsEnforce = "enforce" // Skip disallowed paths and honor Crawl-delay
+)
+
+var errRobotsDisallowed = errors.New("disallowed by robots.txt")
+
+// robotsEntry is a cached robots.txt, nil data meaning none could be fetched
+type robotsEntry struct {
+	data      *robotstxt.RobotsData
+	fetchedAt time.Time
+}
+
+var (
+	robotsCache   = make(map[string]robotsEntry)
+	robotsCacheMu sync.Mutex
+)
+
+// robotsGroup returns the robots.txt rules that apply to our user agent for a
+// URL's host, fetching and caching the file as needed. It returns nil when
+// robots.txt is ignored or couldn't be fetched.
+func robotsGroup(ctx context.Context, u *url.URL) *robotstxt.Group {
+	if cfg.RobotsTxt != RobotsEnforce {
+		return nil
+	}
+	origin := u.Scheme + "://" + u.Host
+
+	robotsCacheMu.Lock()
+	entry, ok := robotsCache[origin]
+	robotsCacheMu.Unlock()
+	if !ok || time.Since(entry.fetchedAt) > cfg.RobotsCacheTTL {
+		data, err := fetchRobots(ctx, origin)
+		if err != nil {
+			logf(ctx, "Failed to fetch robots.txt for %s, allowing scrapes: %v", origin, err)
+		}
+		entry = robotsEntry{data: data, fetchedAt: time.Now()}
+		robotsCacheMu.Lock()
+		robotsCache[origin] = entry
+		robotsCacheMu.Unlock()
+	}
+	if entry.data == nil {
+		return nil
+	}
+	return entry.data.FindGroup(scraper.DefaultOptions.UserAgent)
+}
+
+func fetchRobots(ctx context.Context, origin string) (*robotstxt.RobotsData, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", scraper.DefaultOptions.UserAgent)
+	client := &http.Client{Timeout: 10 * time.Second, Transport: transportFor(origin), CheckRedirect: checkRedirect}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 512*1024))
+	if err != nil {
+		return nil, err
+	}
+	// 4xx allows everything and 5xx disallows everything, as crawlers conventionally do
+	return robotstxt.FromStatusAndBytes(res.StatusCode, body)
+}
+
+// domainGate limits concurrent scrapes of one host and spaces them out
+type domainGate struct {
+	slots chan struct{}
+	mu    sync.Mutex
+	next  time.Time // Earliest start of the next scrape
+}
+
+var (
+	domainGates   = make(map[string]*domainGate)
+	domainGatesMu sync.Mutex
+)
+
+func gateFor(host string, concurrency int) *domainGate {
+	domainGatesMu.Lock()
+	defer domainGatesMu.Unlock()
+	g, ok := domainGates[host]
+	if !ok {
+		if concurrency <= 0 {
+			concurrency = cfg.DomainConcurrency
+		}
+		g = &domainGate{}
+		if concurrency > 0 {
+			g.slots = make(chan struct{}, concurrency)
+		}
+		domainGates[host] = g
+	}
+	return g
+}
+
+// politeScrape checks robots.txt and waits for the domain's concurrency limit and
+// crawl delay before a scrape. The returned function must be called when done.
+func politeScrape(ctx context.Context, rawURL string) (func(), error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	var delay time.Duration
+	if group := robotsGroup(ctx, u); group != nil {
+		if !group.Test(u.RequestURI()) {
+			return nil, fmt.Errorf("%w: %s", errRobotsDisallowed, u.Path)
+		}
+		delay = group.CrawlDelay
+	}
+	concurrency := 0
+	if dc := domainConfigFor(rawURL); dc != nil {
+		concurrency = dc.Concurrency
+		if d, err := time.ParseDuration(dc.CrawlDelay); err == nil {
+			delay = d // Explicit config wins over robots.txt
+		}
+	}
+
+	g := gateFor(breakerKey(rawURL), concurrency)
+	if g.slots != nil {
+		select {
+		case g.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release := func() {
+		if g.slots != nil {
+			<-g.slots
+		}
+	}
+
+	if delay > 0 {
+		g.mu.Lock()
+		start := time.Now()
+		if g.next.After(start) {
+			start = g.next
+		}
+		g.next = start.Add(delay)
+		g.mu.Unlock()
+
+		if wait := time.Until(start); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				release()
+				return nil, ctx.Err()
+			}
+		}
+	}
+	return release, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// parseCron parses a standard five-field cron expression, e.g. "0 9,18 * * 1-5"
+// for weekdays at 9:00 and 18:00. A "CRON_TZ=Asia/Kolkata " prefix sets the timezone.
+func parseCron(expr string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %v", expr, err)
+	}
+	return schedule, nil
+}
+
+// isDue reports whether an item should be checked on this monitor tick. Items
+// without a cron schedule are checked every tick; scheduled items are checked
+// once a scheduled time has passed since their last check.
+func isDue(item TrackingRequest, now time.Time) bool {
+	if item.Cron == "" {
+		return true
+	}
+	schedule, err := parseCron(item.Cron)
+	if err != nil {
+		return false // Validated at track time, so only reachable for stale data
+	}
+	last := now.Add(-checkInterval)
+	if checked, err := time.Parse(time.RFC3339, item.LastCheckedAt); err == nil && checked.After(last) {
+		last = checked
+	}
+	return !schedule.Next(last).After(now)
+}
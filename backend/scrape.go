@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+	"github.com/gocolly/colly/v2/debug"
+
+	"price-tracker-backend/currency"
+	"price-tracker-backend/scraper"
+)
+
+// ScrapeResult is everything scrapePrice learned about a product page
+type ScrapeResult struct {
+	PriceString  string
+	Currency     string         // Detected from PriceString and the URL, e.g. "INR"; empty if unknown
+	Price        currency.Money // After the domain's price transforms
+	ScrapedPrice currency.Money // As parsed from PriceString
+	InStock      bool
+	Title        string // Product name, empty if the page didn't show one
+	ImageURL     string // Main product image, empty if none was found
+	Method       string // Scrape method that found the price, see scrapeMethods
+
+	// Struck-through list price (MSRP) as scraped, 0 if the page showed none
+	// above the current price, and how far below it ScrapedPrice is
+	ListPrice       currency.Money
+	ListPriceString string
+	DiscountPercent float64
+}
+
+// errPriceNotFound is returned when the page has no recognizable price. The
+// accompanying ScrapeResult still carries the stock status.
+var errPriceNotFound = errors.New("price not found")
+
+// errUnparseablePrice is wrapped by errors for price text that isn't a number
+var errUnparseablePrice = errors.New("failed to parse price")
+
+// scrapeAPIError maps a scrape failure to its response: 422 when the page
+// loaded but has no usable price, 400 for a blocked URL, and 502 when the
+// site couldn't be fetched
+func scrapeAPIError(err error) *APIError {
+	switch {
+	case errors.Is(err, errPriceNotFound) || errors.Is(err, errImplausiblePrice) || errors.Is(err, errUnparseablePrice):
+		return newAPIError(http.StatusUnprocessableEntity, ErrCodePriceNotFound, fmt.Sprintf("No usable price on the page: %v", err))
+	case errors.Is(err, errBlockedTarget):
+		return newAPIError(http.StatusBadRequest, ErrCodeBlockedURL, err.Error())
+	default:
+		return newAPIError(http.StatusBadGateway, ErrCodeScrapeFailed, fmt.Sprintf("Unable to fetch price: %v", err))
+	}
+}
+
+// scrapePrice fetches a product page's price, failing fast while the domain's
+// circuit breaker is open
+func scrapePrice(ctx context.Context, url string) (ScrapeResult, error) {
+	if err := breakerAllow(url); err != nil {
+		return ScrapeResult{}, err
+	}
+	done, err := politeScrape(ctx, url)
+	if err != nil {
+		breakerAbandon(url)
+		return ScrapeResult{}, err
+	}
+	result, err := fetchPrice(ctx, url)
+	done()
+	// A missing or implausible price on a page that loaded fine (e.g. out of
+	// stock) isn't a domain failure
+	if errors.Is(err, errPriceNotFound) || errors.Is(err, errImplausiblePrice) {
+		breakerRecord(url, nil)
+	} else {
+		breakerRecord(url, err)
+	}
+	if err == nil {
+		result.Currency = currency.Detect(result.PriceString, url)
+		result.Price = result.Price.In(result.Currency)
+		result.ListPrice = result.ListPrice.In(result.Currency)
+		result.ScrapedPrice = result.Price
+		if result.ListPrice.Cmp(result.ScrapedPrice) > 0 {
+			result.DiscountPercent = -currency.PercentChange(result.ListPrice, result.ScrapedPrice)
+		} else {
+			result.ListPrice, result.ListPriceString = currency.Money{}, ""
+		}
+		if dc := domainConfigFor(url); dc != nil && len(dc.Transforms) > 0 {
+			if result.Price, err = applyTransforms(result.Price, url, dc.Transforms); err == nil {
+				logf(ctx, "Transformed price for %s: %s -> %s", url, result.ScrapedPrice.Decimal(), result.Price.Decimal())
+			}
+		}
+	}
+	cacheScrape(url, result, err)
+	return result, err
+}
+
+// errImplausiblePrice is wrapped by checkPlausible's errors
+var errImplausiblePrice = errors.New("implausible price")
+
+// checkPlausible rejects prices outside the domain's configured plausible range,
+// which usually means the selector matched the wrong element
+func checkPlausible(url string, price currency.Money) error {
+	dc := domainConfigFor(url)
+	if dc == nil {
+		return nil
+	}
+	if dc.MinPlausiblePrice.Sign() > 0 && price.Cmp(dc.MinPlausiblePrice) < 0 {
+		return fmt.Errorf("failed to parse price: %w: %s is below the plausible minimum %s for %s", errImplausiblePrice, price.Decimal(), dc.MinPlausiblePrice.Decimal(), dc.Domain)
+	}
+	if dc.MaxPlausiblePrice.Sign() > 0 && price.Cmp(dc.MaxPlausiblePrice) > 0 {
+		return fmt.Errorf("failed to parse price: %w: %s is above the plausible maximum %s for %s", errImplausiblePrice, price.Decimal(), dc.MaxPlausiblePrice.Decimal(), dc.Domain)
+	}
+	return nil
+}
+
+// Selectors tried on product pages
+const (
+	priceSelectors = ".a-price-whole, .a-price-range .a-offscreen, .a-price .a-offscreen, .a-price-symbol + .a-price-whole"
+	stockSelectors = "#availability, #outOfStock, .out-of-stock"
+	titleSelectors = "#productTitle, meta[property='og:title'], title"
+	// Struck-through list prices, e.g. Amazon's "M.R.P." next to the deal price
+	listPriceSelectors = ".a-text-price[data-a-strike] .a-offscreen, .basisPrice .a-text-price .a-offscreen, #listPrice, #priceblock_listprice"
+)
+
+// productPage is what the product page selectors found on a page
+type productPage struct {
+	priceString     string
+	listPriceString string
+	outOfStock      bool
+	buyingOptions   bool // Amazon listing that only links to other sellers' offers
+	title           string
+	imageURL        string
+}
+
+// readProductPage applies the product page selectors to a page's <html> element
+func readProductPage(root *goquery.Selection, pageURL *url.URL) productPage {
+	var page productPage
+
+	// The site's own selector, choosing among its matches by its pick rule,
+	// then the first non-empty match of any built-in price selector, in
+	// document order
+	if dc := domainConfigFor(pageURL.String()); dc != nil && dc.PriceSelector != nil {
+		page.priceString = dc.PriceSelector.Extract(root, dc.NumberFormat)
+	}
+	if page.priceString == "" {
+		root.Find(priceSelectors).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+			page.priceString = strings.TrimSpace(s.Text())
+			return page.priceString == ""
+		})
+	}
+
+	root.Find(listPriceSelectors).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		page.listPriceString = strings.TrimSpace(s.Text())
+		return page.listPriceString == ""
+	})
+
+	// Out-of-stock markers
+	root.Find(stockSelectors).Each(func(_ int, s *goquery.Selection) {
+		if s.AttrOr("id", "") == "outOfStock" || scraper.IsOutOfStockText(s.Text()) {
+			page.outOfStock = true
+		}
+	})
+
+	// Product title, preferring Amazon's own element over og:title over <title>
+	var titles [3]string // #productTitle, og:title, <title>
+	root.Find(titleSelectors).Each(func(_ int, s *goquery.Selection) {
+		rank, text := 2, s.Text()
+		switch {
+		case s.AttrOr("id", "") == "productTitle":
+			rank = 0
+		case goquery.NodeName(s) == "meta":
+			rank, text = 1, s.AttrOr("content", "")
+		}
+		if text = strings.Join(strings.Fields(text), " "); titles[rank] == "" {
+			titles[rank] = text
+		}
+	})
+	for _, title := range titles {
+		if page.title == "" {
+			page.title = title
+		}
+	}
+
+	page.imageURL = scraper.ExtractImage(root, pageURL)
+	// Amazon listings without a buy box link to the other sellers' offers instead
+	page.buyingOptions = root.Find(buyingOptionsSelectors).Length() > 0
+	return page
+}
+
+// fetchHTML scrapes the product page's static HTML
+func fetchHTML(ctx context.Context, url string) (ScrapeResult, error) {
+	c := newCollector(ctx, url)
+
+	var status int
+	var body []byte
+	c.OnResponse(func(r *colly.Response) {
+		status, body = r.StatusCode, r.Body
+	})
+
+	var page productPage
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		page = readProductPage(e.DOM, e.Request.URL)
+	})
+
+	err := c.Visit(url)
+	if err != nil {
+		return ScrapeResult{}, err
+	}
+	return priceFromPage(ctx, url, page, status, body)
+}
+
+// priceFromPage parses the price a product page showed, falling back to
+// Amazon's buying options when it showed none
+func priceFromPage(ctx context.Context, url string, page productPage, status int, body []byte) (ScrapeResult, error) {
+	result := ScrapeResult{InStock: !page.outOfStock, Title: page.title, ImageURL: page.imageURL}
+	if page.listPriceString != "" {
+		if listPrice, err := parseScrapedPrice(page.listPriceString, priceFormatFor(url)); err == nil {
+			result.ListPrice, result.ListPriceString = listPrice, page.listPriceString
+		}
+	}
+	if page.priceString == "" && page.buyingOptions && cfg.FollowBuyingOptions {
+		if asin := amazonASIN(url); asin != "" {
+			logf(ctx, "No price on %s, checking buying options for %s", url, asin)
+			offerString, offerPrice, err := fetchLowestOffer(ctx, url, asin)
+			if err == nil {
+				result.PriceString, result.Price, result.InStock = offerString, offerPrice, true
+				return result, nil
+			}
+			logf(ctx, "Failed to read buying options for %s: %v", asin, err)
+		}
+	}
+	if page.priceString == "" {
+		dumpScrapedHTML(ctx, url, status, body, errPriceNotFound)
+		return result, errPriceNotFound
+	}
+
+	price, err := parseScrapedPrice(page.priceString, priceFormatFor(url))
+	result.PriceString = page.priceString
+	if err != nil {
+		dumpScrapedHTML(ctx, url, status, body, err)
+		return result, err
+	}
+	result.Price = price
+	return result, nil
+}
+
+// builtinDomains are the hosts whose product pages are scraped without a domain config
+var builtinDomains = []string{"www.amazon.in", "amazon.in", "www.amazon.com", "amazon.com"}
+
+// newCollector returns a collector set up like a regular browser visit to pageURL's site
+func newCollector(ctx context.Context, pageURL string) *colly.Collector {
+	c := colly.NewCollector(
+		colly.Debugger(&debug.LogDebugger{}),
+	)
+	c.WithTransport(transportFor(pageURL))
+	c.SetRedirectHandler(checkRedirect)
+	c.MaxBodySize = int(cfg.MaxScrapeBody)
+	itemOpts := scraperOptions(ctx)
+	if timeout := itemOpts.timeout(); timeout > 0 {
+		c.SetRequestTimeout(timeout)
+	}
+
+	// Add multiple domains to avoid blocking, and the page's own site when it
+	// has a domain config
+	c.AllowedDomains = slices.Clone(builtinDomains)
+	if dc := domainConfigFor(pageURL); dc != nil {
+		c.AllowedDomains = append(c.AllowedDomains, dc.Domain, "www."+dc.Domain)
+		if u, err := url.Parse(pageURL); err == nil {
+			c.AllowedDomains = append(c.AllowedDomains, strings.ToLower(u.Hostname()))
+		}
+	}
+
+	// Set realistic headers to avoid detection
+	c.OnRequest(func(r *colly.Request) {
+		r.Headers.Set("User-Agent", cmp.Or(itemOpts.UserAgent, scraper.DefaultOptions.UserAgent))
+		r.Headers.Set("Accept", scraper.DefaultOptions.Accept)
+		r.Headers.Set("Accept-Language", scraper.DefaultOptions.AcceptLanguage)
+		r.Headers.Set("Accept-Encoding", "gzip, deflate")
+		r.Headers.Set("Upgrade-Insecure-Requests", "1")
+		r.Headers.Set("Referer", refererFor(r.URL))
+		if auth := authHeaderFor(r.URL.String()); auth != "" {
+			r.Headers.Set("Authorization", auth)
+		}
+	})
+
+	c.OnError(func(r *colly.Response, err error) {
+		logf(ctx, "Error occurred: %v", err)
+	})
+
+	// Add delay to avoid rate limiting
+	c.Limit(&colly.LimitRule{
+		DomainGlob:  "*amazon.*",
+		Parallelism: 1,
+		Delay:       2 * time.Second,
+	})
+	return c
+}
+
+// parseScrapedPrice parses a scraped price such as "60,100" or "₹1,299.00",
+// or "1.234,56 €" when the site's separators are configured
+func parseScrapedPrice(priceString string, format scraper.NumberFormat) (currency.Money, error) {
+	if format.Set() {
+		price, err := scraper.ParsePriceStringFormat(priceString, format)
+		if err != nil {
+			return currency.Money{}, fmt.Errorf("%w: %v", errUnparseablePrice, err)
+		}
+		return price, nil
+	}
+	// Parse Indian price format (e.g., "60,100" to 60100), stripping any currency symbol
+	cleanPrice := strings.NewReplacer(",", "", "₹", "", "$", "", "€", "", "£", "").Replace(priceString)
+	cleanPrice = strings.TrimSpace(cleanPrice)
+
+	price, err := currency.ParseMoney(cleanPrice, "")
+	if err != nil {
+		return currency.Money{}, fmt.Errorf("%w: %v", errUnparseablePrice, err)
+	}
+	return price, nil
+}
+
+// scrapeJSONEndpoint fetches the price from a site's JSON API as described by its domain config
+func scrapeJSONEndpoint(ctx context.Context, itemURL string, dc *DomainConfig) (ScrapeResult, error) {
+	data, err := newTemplateData(itemURL)
+	if err != nil {
+		return ScrapeResult{}, err
+	}
+	endpoint := itemURL
+	if dc.endpointRegexp != nil {
+		opts := scraper.DefaultOptions
+		opts.UserAgent = cmp.Or(scraperOptions(ctx).UserAgent, opts.UserAgent)
+		opts.Timeout = cmp.Or(scraperOptions(ctx).timeout(), opts.Timeout)
+		opts.Transport = transportFor(itemURL)
+		opts.CheckRedirect = checkRedirect
+		opts.Authorization = authHeaderFor(itemURL)
+		if u, err := url.Parse(itemURL); err == nil {
+			opts.Referer = refererFor(u)
+		}
+		if endpoint, err = scraper.DiscoverEndpoint(itemURL, dc.endpointRegexp, opts); err != nil {
+			return ScrapeResult{}, err
+		}
+		logf(ctx, "Discovered price endpoint %s for %s", endpoint, itemURL)
+	} else if dc.Endpoint != "" {
+		if endpoint, err = renderTemplate("endpoint", dc.Endpoint, data); err != nil {
+			return ScrapeResult{}, err
+		}
+	}
+	body := ""
+	if dc.BodyTemplate != "" {
+		if body, err = renderTemplate("body", dc.BodyTemplate, data); err != nil {
+			return ScrapeResult{}, err
+		}
+	}
+
+	headers := map[string]string{}
+	if ua := scraperOptions(ctx).UserAgent; ua != "" {
+		headers["User-Agent"] = ua
+	}
+	if u, err := url.Parse(endpoint); err == nil {
+		headers["Referer"] = refererFor(u)
+	}
+	if auth := authHeaderFor(endpoint); auth != "" {
+		headers["Authorization"] = auth
+	}
+	for k, v := range dc.Headers {
+		headers[k] = v // Explicit headers win over the default Referer
+	}
+	price, priceString, err := scraper.ScrapeJSON(scraper.JSONRequest{
+		Transport:     transportFor(endpoint),
+		CheckRedirect: checkRedirect,
+		Method:        dc.Method,
+		URL:           endpoint,
+		Body:          body,
+		Headers:       headers,
+		PricePath:     dc.PricePath,
+		Format:        dc.NumberFormat,
+		Timeout:       scraperOptions(ctx).timeout(),
+		MaxBodySize:   cfg.MaxScrapeBody,
+	})
+	if err != nil {
+		return ScrapeResult{}, err
+	}
+	// JSON endpoints don't report stock status; a returned price implies it can be bought
+	return ScrapeResult{PriceString: priceString, Price: price, InStock: true}, nil
+}
+
+func renderTemplate(name, text string, data templateData) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// normalizePrice detects the currency of a scraped price and converts it to the base
+// currency. When no base currency is configured, prices are compared as scraped.
+func normalizePrice(url, priceString string, price currency.Money) (string, currency.Money, error) {
+	code := currency.Detect(priceString, url)
+	converted, err := toBaseCurrency(price, code)
+	if err != nil {
+		return code, currency.Money{}, err
+	}
+	return code, converted, nil
+}
+
+// toBaseCurrency converts a price in code to the base currency, if one is set
+func toBaseCurrency(price currency.Money, code string) (currency.Money, error) {
+	if converter.Base == "" {
+		return price, nil
+	}
+	return converter.Convert(price.In(code))
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Scrape auth schemes
+const (
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+)
+
+// ScrapeAuth is the credential a domain's scrape requests are sent with, for
+// prices behind a login. Secrets are best kept out of the config file by naming
+// an environment variable in PasswordEnv or TokenEnv.
+type ScrapeAuth struct {
+	Type        string `json:"type"` // "basic" or "bearer"
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"password,omitempty"`
+	PasswordEnv string `json:"passwordEnv,omitempty"`
+	Token       string `json:"token,omitempty"`
+	TokenEnv    string `json:"tokenEnv,omitempty"`
+
+	header string // Resolved Authorization header value
+}
+
+// resolve checks the credential and builds its Authorization header, reading
+// secrets from the environment where configured
+func (a *ScrapeAuth) resolve() error {
+	switch a.Type {
+	case AuthBasic:
+		password := a.Password
+		if a.PasswordEnv != "" {
+			password = os.Getenv(a.PasswordEnv)
+		}
+		if a.Username == "" || password == "" {
+			return fmt.Errorf("basic auth needs username and password or passwordEnv")
+		}
+		a.header = "Basic " + base64.StdEncoding.EncodeToString([]byte(a.Username+":"+password))
+	case AuthBearer:
+		token := a.Token
+		if a.TokenEnv != "" {
+			token = os.Getenv(a.TokenEnv)
+		}
+		if token == "" {
+			return fmt.Errorf("bearer auth needs token or tokenEnv")
+		}
+		a.header = "Bearer " + token
+	default:
+		return fmt.Errorf("unknown auth type %q, expected %q or %q", a.Type, AuthBasic, AuthBearer)
+	}
+	return nil
+}
+
+// String and MarshalJSON keep secrets out of logs and API responses
+func (a ScrapeAuth) String() string {
+	if a.Username != "" {
+		return fmt.Sprintf("%s auth as %s", a.Type, a.Username)
+	}
+	return a.Type + " auth"
+}
+
+func (a ScrapeAuth) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"type": a.Type, "username": a.Username})
+}
+
+// authHeaderFor returns the Authorization header for a request URL, or "" when
+// its domain has no credential. Credentials are only sent to their own domain.
+func authHeaderFor(rawURL string) string {
+	if dc := domainConfigFor(rawURL); dc != nil && dc.Auth != nil {
+		return dc.Auth.header
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// cachedScrape is the latest usable scrape of a URL
+type cachedScrape struct {
+	result    ScrapeResult
+	err       error // nil or errPriceNotFound, which still carries the stock status
+	fetchedAt time.Time
+}
+
+// Recent scrapes by URL, so the monitor doesn't scrape a page again right after
+// a check-price request or an on-demand check did
+var (
+	scrapeCache   = make(map[string]cachedScrape)
+	scrapeCacheMu sync.Mutex
+	scrapeFlight  singleflight.Group
+)
+
+// cacheScrape keeps a scrape's result for SCRAPE_CACHE_TTL. Failures other
+// than a missing price aren't kept, so they're retried.
+func cacheScrape(url string, result ScrapeResult, err error) {
+	if cfg.ScrapeCacheTTL <= 0 || (err != nil && !errors.Is(err, errPriceNotFound)) {
+		return
+	}
+	now := time.Now()
+	scrapeCacheMu.Lock()
+	defer scrapeCacheMu.Unlock()
+	for other, entry := range scrapeCache {
+		if now.Sub(entry.fetchedAt) >= cfg.ScrapeCacheTTL {
+			delete(scrapeCache, other)
+		}
+	}
+	scrapeCache[url] = cachedScrape{result: result, err: err, fetchedAt: now}
+}
+
+// recentScrape returns the URL's cached scrape if it's still fresh
+func recentScrape(url string) (cachedScrape, bool) {
+	scrapeCacheMu.Lock()
+	defer scrapeCacheMu.Unlock()
+	entry, ok := scrapeCache[url]
+	if !ok || time.Since(entry.fetchedAt) >= cfg.ScrapeCacheTTL {
+		return cachedScrape{}, false
+	}
+	return entry, true
+}
+
+// freshScrapeKey marks a context whose checks must scrape the page again
+// rather than reuse a recent scrape, e.g. on-demand checks
+type freshScrapeKey struct{}
+
+func withFreshScrape(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshScrapeKey{}, true)
+}
+
+// checkScrape scrapes an item's page for a check, reusing a scrape from within
+// SCRAPE_CACHE_TTL and joining one that's in progress. It reports
+// alreadyChecked, with nothing left to do, when the item was checked with the
+// reused scrape before.
+func checkScrape(ctx context.Context, item TrackingRequest) (entry cachedScrape, alreadyChecked bool, err error) {
+	// Items with their own scraper options can't share scrapes made without them
+	if fresh, _ := ctx.Value(freshScrapeKey{}).(bool); fresh || item.ScraperOptions != nil {
+		result, err := scrapeItem(ctx, item)
+		return cachedScrape{result: result, err: err, fetchedAt: time.Now()}, false, err
+	}
+	if entry, ok := recentScrape(item.URL); ok {
+		if checked, err := time.Parse(time.RFC3339, item.LastCheckedAt); err == nil && !checked.Before(entry.fetchedAt.Truncate(time.Second)) {
+			return entry, true, nil
+		}
+		logf(ctx, "Reusing the scrape of %s from %s ago", item.URL, time.Since(entry.fetchedAt).Round(time.Second))
+		return entry, false, entry.err
+	}
+	v, err, shared := scrapeFlight.Do(item.URL, func() (interface{}, error) {
+		result, err := scrapeItem(ctx, item)
+		return cachedScrape{result: result, err: err, fetchedAt: time.Now()}, err
+	})
+	if shared {
+		logf(ctx, "Joined a scrape of %s already in progress", item.URL)
+	}
+	return v.(cachedScrape), false, err
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"price-tracker-backend/scraper"
+)
+
+// Scrape methods a domain's fallback chain is made of, cheapest first
+const (
+	MethodHTML     = "html"     // The page's static HTML
+	MethodJSON     = "json"     // The site's JSON endpoint, see DomainConfig.PricePath
+	MethodHeadless = "headless" // The page rendered in headless Chrome
+)
+
+// validateMethods checks a domain's scrape method chain
+func validateMethods(dc DomainConfig) error {
+	for _, method := range dc.Methods {
+		switch method {
+		case MethodHTML, MethodHeadless:
+		case MethodJSON:
+			if dc.PricePath == "" {
+				return fmt.Errorf("method %q needs pricePath", method)
+			}
+		default:
+			return fmt.Errorf("unknown scrape method %q, expected %q, %q or %q", method, MethodHTML, MethodJSON, MethodHeadless)
+		}
+	}
+	return nil
+}
+
+// scrapeMethods is the chain of methods tried for a URL: the item's own, the
+// domain's, or its JSON endpoint when it has one, or the static HTML
+func scrapeMethods(ctx context.Context, dc *DomainConfig) []string {
+	switch {
+	case len(scraperOptions(ctx).Methods) > 0:
+		return scraperOptions(ctx).Methods
+	case dc == nil:
+		return []string{MethodHTML}
+	case len(dc.Methods) > 0:
+		return dc.Methods
+	case dc.PricePath != "":
+		return []string{MethodJSON}
+	}
+	return []string{MethodHTML}
+}
+
+// Prices found per scrape method, and scrapes no method found a price for
+var (
+	methodCounts   = map[string]int{}
+	methodFailures int
+	methodMu       sync.Mutex
+)
+
+func countMethod(method string) {
+	methodMu.Lock()
+	defer methodMu.Unlock()
+	if method == "" {
+		methodFailures++
+		return
+	}
+	methodCounts[method]++
+}
+
+// methodStats reports how often each scrape method found the price
+func methodStats() map[string]interface{} {
+	methodMu.Lock()
+	defer methodMu.Unlock()
+	counts := make(map[string]int, len(methodCounts))
+	for method, n := range methodCounts {
+		counts[method] = n
+	}
+	return map[string]interface{}{"succeeded": counts, "failed": methodFailures}
+}
+
+// fetchPrice tries the domain's scrape methods in order and returns the first
+// plausible price. When all fail, the last method's result and error are
+// returned, so stock status from a page without a price isn't lost.
+func fetchPrice(ctx context.Context, url string) (ScrapeResult, error) {
+	dc := domainConfigFor(url)
+	methods := scrapeMethods(ctx, dc)
+
+	var result ScrapeResult
+	var err error
+	for i, method := range methods {
+		switch method {
+		case MethodJSON:
+			result, err = scrapeJSONEndpoint(ctx, url, dc)
+		case MethodHeadless:
+			result, err = fetchRendered(ctx, url, dc)
+		default:
+			result, err = fetchHTML(ctx, url)
+		}
+		if err == nil {
+			err = checkPlausible(url, result.Price)
+		}
+		if err == nil {
+			result.Method = method
+			countMethod(method)
+			if i > 0 {
+				logf(ctx, "Found the price for %s with the %s method", url, method)
+			}
+			return result, nil
+		}
+		if i < len(methods)-1 {
+			logf(ctx, "Scrape method %s failed for %s, trying %s: %v", method, url, methods[i+1], err)
+		}
+	}
+	countMethod("")
+	return result, err
+}
+
+// fetchRendered scrapes the product page after rendering it in headless Chrome
+func fetchRendered(ctx context.Context, pageURL string, dc *DomainConfig) (ScrapeResult, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return ScrapeResult{}, err
+	}
+	if err := checkScrapeTarget(ctx, pageURL); err != nil {
+		return ScrapeResult{}, err
+	}
+	itemOpts := scraperOptions(ctx)
+	renderCtx, cancel := context.WithTimeout(ctx, cmp.Or(itemOpts.timeout(), cfg.HeadlessTimeout))
+	defer cancel()
+
+	opts := scraper.HeadlessOptions{
+		RemoteURL: cfg.ChromeURL,
+		UserAgent: cmp.Or(itemOpts.UserAgent, scraper.DefaultOptions.UserAgent),
+		// Chrome resolves hosts and follows redirects itself, so every request
+		// the page makes, not just the first, goes through the SSRF guard
+		AllowRequest: checkScrapeTarget,
+	}
+	if dc != nil {
+		opts.WaitFor = dc.WaitFor
+	}
+	opts.WaitFor = cmp.Or(itemOpts.WaitFor, opts.WaitFor)
+	body, err := scraper.RenderPage(renderCtx, pageURL, opts)
+	if err != nil {
+		return ScrapeResult{}, err
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return ScrapeResult{}, fmt.Errorf("failed to parse rendered page: %w", err)
+	}
+	return priceFromPage(ctx, pageURL, readProductPage(doc.Find("html"), u), http.StatusOK, body)
+}
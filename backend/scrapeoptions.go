@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/andybalholm/cascadia"
+)
+
+// Bounds on an item's scraper options
+const (
+	maxScrapeRetries = 5
+	maxScrapeTimeout = 5 * time.Minute
+)
+
+// ItemScraperOptions override the global scraper settings for one item, e.g. to
+// render a problematic site in headless Chrome without slowing down the others.
+// Fields left unset keep the domain's or global setting.
+type ItemScraperOptions struct {
+	Methods   []string `json:"methods,omitempty"`   // Scrape method chain, e.g. ["headless"], see DomainConfig.Methods
+	Timeout   string   `json:"timeout,omitempty"`   // Limit on each request or headless render, e.g. "20s"
+	Retries   int      `json:"retries,omitempty"`   // Attempts after a failed fetch; a page without a price isn't retried
+	WaitFor   string   `json:"waitFor,omitempty"`   // Selector the headless method waits for
+	UserAgent string   `json:"userAgent,omitempty"` // User-Agent header, SCRAPER_USER_AGENT by default
+}
+
+// validate checks the options for an item on rawURL's site
+func (o *ItemScraperOptions) validate(rawURL string) error {
+	dc := DomainConfig{Methods: o.Methods}
+	if site := domainConfigFor(rawURL); site != nil {
+		dc.PricePath = site.PricePath
+	}
+	if err := validateMethods(dc); err != nil {
+		return err
+	}
+	if o.Timeout != "" {
+		if d, err := time.ParseDuration(o.Timeout); err != nil || d <= 0 || d > maxScrapeTimeout {
+			return fmt.Errorf("invalid timeout %q, expected a duration up to %s such as \"20s\"", o.Timeout, maxScrapeTimeout)
+		}
+	}
+	if o.Retries < 0 || o.Retries > maxScrapeRetries {
+		return fmt.Errorf("retries must be between 0 and %d", maxScrapeRetries)
+	}
+	if o.WaitFor != "" {
+		if _, err := cascadia.Compile(o.WaitFor); err != nil {
+			return fmt.Errorf("invalid waitFor selector: %v", err)
+		}
+	}
+	return nil
+}
+
+// timeout is the item's Timeout, 0 when unset
+func (o ItemScraperOptions) timeout() time.Duration {
+	d, _ := time.ParseDuration(o.Timeout)
+	return d
+}
+
+// scraperOptionsKey carries an item's scraper options to the scrape methods
+type scraperOptionsKey struct{}
+
+func withScraperOptions(ctx context.Context, opts *ItemScraperOptions) context.Context {
+	if opts == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, scraperOptionsKey{}, *opts)
+}
+
+// scraperOptions returns the options of the item being scraped, zero without any
+func scraperOptions(ctx context.Context) ItemScraperOptions {
+	opts, _ := ctx.Value(scraperOptionsKey{}).(ItemScraperOptions)
+	return opts
+}
+
+// retryableScrape reports whether a failed scrape may succeed when tried again
+// right away: not when the page had no usable price, or the scrape was refused
+func retryableScrape(ctx context.Context, err error) bool {
+	for _, final := range []error{errPriceNotFound, errImplausiblePrice, errUnparseablePrice, errBlockedTarget, errCircuitOpen, errThrottled, errRobotsDisallowed} {
+		if errors.Is(err, final) {
+			return false
+		}
+	}
+	return err != nil && ctx.Err() == nil
+}
+
+// scrapeItem scrapes a tracked item with its scraper options, retrying failed
+// fetches as many times as they allow
+func scrapeItem(ctx context.Context, item TrackingRequest) (ScrapeResult, error) {
+	ctx = withScraperOptions(ctx, item.ScraperOptions)
+	retries := scraperOptions(ctx).Retries
+	result, err := scrapePrice(ctx, item.URL)
+	for attempt := 1; attempt <= retries && retryableScrape(ctx, err); attempt++ {
+		logf(ctx, "Scrape of %s failed, retrying (%d of %d): %v", item.ID, attempt, retries, err)
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-time.After(time.Duration(attempt) * time.Second):
+		}
+		result, err = scrapePrice(ctx, item.URL)
+	}
+	return result, err
+}
@@ -0,0 +1,42 @@
+// backend/scraper/discover.go
+package scraper
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DiscoverEndpoint fetches a product page and extracts an API URL from its HTML
+// with pattern. The first capture group is used if the pattern has one, otherwise
+// the whole match. Relative URLs are resolved against the page, and the JSON and
+// HTML escaping pages commonly embed URLs with is undone.
+func DiscoverEndpoint(pageURL string, pattern *regexp.Regexp, opts ScraperOptions) (string, error) {
+	body, err := fetchPage(pageURL, opts)
+	if err != nil {
+		return "", err
+	}
+	match := pattern.FindSubmatch(body)
+	if match == nil {
+		return "", fmt.Errorf("no API endpoint matching %q found on page", pattern.String())
+	}
+	raw := string(match[0])
+	if len(match) > 1 {
+		raw = string(match[1])
+	}
+	raw = strings.ReplaceAll(raw, `\/`, "/")
+	raw = strings.ReplaceAll(raw, `\u0026`, "&")
+	raw = html.UnescapeString(strings.TrimSpace(raw))
+
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid page URL: %w", err)
+	}
+	ref, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("discovered endpoint %q is not a URL: %w", raw, err)
+	}
+	return base.ResolveReference(ref).String(), nil
+}
@@ -0,0 +1,95 @@
+// backend/scraper/format.go
+package scraper
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"price-tracker-backend/currency"
+)
+
+// NumberFormat names the separators a site writes prices with, e.g. Decimal
+// "," and Group "." for "1.234,56". The zero value leaves ParsePriceString to
+// guess them.
+type NumberFormat struct {
+	Decimal string `json:"decimal,omitempty"` // "." or ","
+	Group   string `json:"group,omitempty"`   // Thousands separator, by default the other of "." and ","
+}
+
+// Set reports whether the separators are given rather than guessed
+func (f NumberFormat) Set() bool {
+	return f.Decimal != "" || f.Group != ""
+}
+
+// Validate checks the separators and fills in the one left out: the decimal
+// separator defaults to "." unless that's the group separator, and the group
+// separator to the other of "." and ","
+func (f *NumberFormat) Validate() error {
+	if !f.Set() {
+		return nil
+	}
+	if f.Decimal == "" {
+		f.Decimal = "."
+		if f.Group == "." {
+			f.Decimal = ","
+		}
+	}
+	if f.Decimal != "." && f.Decimal != "," {
+		return fmt.Errorf("invalid decimal separator %q, expected \".\" or \",\"", f.Decimal)
+	}
+	if f.Group == "" {
+		f.Group = map[string]string{".": ",", ",": "."}[f.Decimal]
+	}
+	switch f.Group {
+	case ".", ",", " ", "'", "’":
+	default:
+		return fmt.Errorf("invalid group separator %q, expected \".\", \",\", \" \" or \"'\"", f.Group)
+	}
+	if f.Group == f.Decimal {
+		return fmt.Errorf("decimal and group separators are both %q", f.Decimal)
+	}
+	return nil
+}
+
+// ParsePriceStringFormat parses a price written with the given separators,
+// such as "€ 1.234,56" with Decimal ",". Text around the number, like a
+// currency symbol, is ignored, and so are spaces between its digits. Without
+// separators it's ParsePriceString.
+func ParsePriceStringFormat(priceStr string, f NumberFormat) (currency.Money, error) {
+	if !f.Set() {
+		return ParsePriceString(priceStr)
+	}
+	if err := f.Validate(); err != nil {
+		return currency.Money{}, err
+	}
+	start := strings.IndexFunc(priceStr, unicode.IsDigit)
+	end := strings.LastIndexFunc(priceStr, unicode.IsDigit)
+	if start < 0 {
+		return currency.Money{}, fmt.Errorf("no digits in %q", priceStr)
+	}
+	number := priceStr[start : end+1]
+
+	var b strings.Builder
+	decimals := 0
+	for _, r := range number {
+		switch s := string(r); {
+		case unicode.IsDigit(r):
+			b.WriteRune(r)
+		case s == f.Decimal:
+			if decimals++; decimals > 1 {
+				return currency.Money{}, fmt.Errorf("more than one decimal separator %q in %q", f.Decimal, priceStr)
+			}
+			b.WriteByte('.')
+		case s == f.Group || unicode.IsSpace(r):
+		default:
+			return currency.Money{}, fmt.Errorf("unexpected %q in price %q", s, priceStr)
+		}
+	}
+
+	price, err := currency.ParseMoney(b.String(), "")
+	if err != nil {
+		return currency.Money{}, fmt.Errorf("could not parse '%s' as a price: %w", priceStr, err)
+	}
+	return price, nil
+}
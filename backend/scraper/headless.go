@@ -0,0 +1,93 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// HeadlessOptions configures RenderPage
+type HeadlessOptions struct {
+	// RemoteURL is the DevTools websocket of a running Chrome, e.g.
+	// "ws://localhost:9222"; empty starts a local headless Chrome per render
+	RemoteURL string
+	UserAgent string
+	// WaitFor is a selector to wait for before reading the page, e.g. the price
+	// element of a site that renders it with JavaScript; empty waits for the body
+	WaitFor string
+	// AllowRequest is asked about every request the page makes, including
+	// redirects, scripts and XHR; requests it returns an error for are blocked.
+	// Nil allows all of them.
+	AllowRequest func(ctx context.Context, url string) error
+}
+
+// RenderPage loads a page in headless Chrome and returns its HTML after scripts
+// have run, for sites that only show prices client-side. ctx bounds the render.
+func RenderPage(ctx context.Context, pageURL string, opts HeadlessOptions) ([]byte, error) {
+	var allocCtx context.Context
+	var cancelAlloc context.CancelFunc
+	if opts.RemoteURL != "" {
+		allocCtx, cancelAlloc = chromedp.NewRemoteAllocator(ctx, opts.RemoteURL)
+	} else {
+		allocOpts := chromedp.DefaultExecAllocatorOptions[:]
+		if opts.UserAgent != "" {
+			allocOpts = append(allocOpts, chromedp.UserAgent(opts.UserAgent))
+		}
+		allocCtx, cancelAlloc = chromedp.NewExecAllocator(ctx, allocOpts...)
+	}
+	defer cancelAlloc()
+
+	browserCtx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+
+	var actions []chromedp.Action
+	if opts.AllowRequest != nil {
+		chromedp.ListenTarget(browserCtx, func(ev interface{}) {
+			if ev, ok := ev.(*fetch.EventRequestPaused); ok {
+				// Event handlers mustn't block, so answer from a goroutine
+				go filterRequest(browserCtx, ev, opts.AllowRequest)
+			}
+		})
+		actions = append(actions, fetch.Enable())
+	}
+
+	waitFor := opts.WaitFor
+	if waitFor == "" {
+		waitFor = "body"
+	}
+	var html string
+	err := chromedp.Run(browserCtx, append(actions,
+		chromedp.Navigate(pageURL),
+		chromedp.WaitReady(waitFor, chromedp.ByQuery),
+		chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+	)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render page: %w", err)
+	}
+	return []byte(html), nil
+}
+
+// filterRequest continues or fails a request paused by the Fetch domain
+// depending on what allow says about its URL
+func filterRequest(browserCtx context.Context, ev *fetch.EventRequestPaused, allow func(context.Context, string) error) {
+	c := chromedp.FromContext(browserCtx)
+	if c == nil || c.Target == nil {
+		return
+	}
+	ctx := cdp.WithExecutor(browserCtx, c.Target)
+	var err error
+	if allowErr := allow(browserCtx, ev.Request.URL); allowErr != nil {
+		log.Printf("Blocked headless request to %s: %v", ev.Request.URL, allowErr)
+		err = fetch.FailRequest(ev.RequestID, network.ErrorReasonBlockedByClient).Do(ctx)
+	} else {
+		err = fetch.ContinueRequest(ev.RequestID).Do(ctx)
+	}
+	if err != nil && browserCtx.Err() == nil {
+		log.Printf("Failed to answer paused headless request to %s: %v", ev.Request.URL, err)
+	}
+}
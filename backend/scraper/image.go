@@ -0,0 +1,56 @@
+// backend/scraper/image.go
+package scraper
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// imageSources lists where product images are found, best first: the element
+// and the attributes that may hold its URL
+var imageSources = []struct {
+	selector string
+	attrs    []string
+}{
+	{"#landingImage", []string{"data-old-hires", "src"}},
+	{"#imgBlkFront", []string{"data-old-hires", "src"}},
+	{"meta[property='og:image']", []string{"content"}},
+	{"meta[name='twitter:image']", []string{"content"}},
+	{"link[rel='image_src']", []string{"href"}},
+}
+
+// ExtractImage finds the main product image on a page and returns its absolute
+// URL, or "" if there is none. Inline data: images are skipped.
+func ExtractImage(page *goquery.Selection, pageURL *url.URL) string {
+	for _, source := range imageSources {
+		el := page.Find(source.selector).First()
+		for _, attr := range source.attrs {
+			value := strings.TrimSpace(el.AttrOr(attr, ""))
+			if value == "" || strings.HasPrefix(value, "data:") {
+				continue
+			}
+			ref, err := url.Parse(value)
+			if err != nil {
+				continue
+			}
+			if pageURL != nil {
+				ref = pageURL.ResolveReference(ref)
+			}
+			if ref.Scheme == "http" || ref.Scheme == "https" {
+				return ref.String()
+			}
+		}
+	}
+	return ""
+}
+
+// ScrapeImage fetches a page and returns its main product image URL
+func ScrapeImage(urlStr string) (string, error) {
+	doc, err := fetchDocument(urlStr, DefaultOptions)
+	if err != nil {
+		return "", err
+	}
+	return ExtractImage(doc.Selection, doc.Url), nil
+}
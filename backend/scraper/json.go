@@ -0,0 +1,144 @@
+// backend/scraper/json.go
+package scraper
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"price-tracker-backend/currency"
+)
+
+// JSONRequest describes a request to a JSON price endpoint
+type JSONRequest struct {
+	Method      string
+	URL         string
+	Body        string
+	Headers     map[string]string
+	PricePath   string            // Path to the price in the response, see extractJSONPath
+	Transport   http.RoundTripper // Optional, e.g. to force an HTTP version
+	Format      NumberFormat      // Separators of prices given as strings, guessed if unset
+	Timeout     time.Duration     // Optional, 30s by default
+	MaxBodySize int64             // Largest response read, in bytes; 0 for no limit
+	// CheckRedirect vets each redirect like http.Client's, nil for the default
+	CheckRedirect func(req *http.Request, via []*http.Request) error
+}
+
+var jsonClient = &http.Client{Timeout: 30 * time.Second}
+
+// ScrapeJSON requests a JSON endpoint and reads the price at PricePath.
+// It returns the price and the raw value it was parsed from.
+func ScrapeJSON(req JSONRequest) (currency.Money, string, error) {
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+		if req.Body != "" {
+			method = http.MethodPost
+		}
+	}
+	log.Printf("Requesting JSON price endpoint: %s %s", method, req.URL)
+
+	var body io.Reader
+	if req.Body != "" {
+		body = strings.NewReader(req.Body)
+	}
+	httpReq, err := http.NewRequest(strings.ToUpper(method), req.URL, body)
+	if err != nil {
+		return currency.Money{}, "", fmt.Errorf("failed to build request: %w", err)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if req.Body != "" {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range req.Headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	client := jsonClient
+	if req.Transport != nil || req.Timeout > 0 || req.CheckRedirect != nil {
+		client = &http.Client{
+			Timeout:       cmp.Or(req.Timeout, jsonClient.Timeout),
+			Transport:     req.Transport,
+			CheckRedirect: req.CheckRedirect,
+		}
+	}
+	res, err := client.Do(httpReq)
+	if err != nil {
+		return currency.Money{}, "", fmt.Errorf("failed to get URL: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return currency.Money{}, "", fmt.Errorf("bad status: %s", res.Status)
+	}
+
+	raw, err := readBody(res.Body, req.MaxBodySize)
+	if err != nil {
+		return currency.Money{}, "", fmt.Errorf("failed to read response: %w", err)
+	}
+	var data interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return currency.Money{}, "", fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	return extractJSONPath(data, req.PricePath, req.Format)
+}
+
+// extractJSONPath reads a price from decoded JSON. The value may be a number or
+// a string such as "₹1,299.00", read in format. It returns the price and the
+// raw value it came from.
+func extractJSONPath(data interface{}, path string, format NumberFormat) (currency.Money, string, error) {
+	value, err := lookupPath(data, path)
+	if err != nil {
+		return currency.Money{}, "", err
+	}
+	switch v := value.(type) {
+	case float64:
+		return currency.FromFloat(v, ""), strconv.FormatFloat(v, 'f', -1, 64), nil
+	case string:
+		price, err := ParsePriceStringFormat(v, format)
+		if err != nil {
+			return currency.Money{}, v, fmt.Errorf("value at %q is not a price: %w", path, err)
+		}
+		return price, v, nil
+	default:
+		return currency.Money{}, "", fmt.Errorf("value at %q is not a number or string: %v", path, value)
+	}
+}
+
+// lookupPath walks a path like "data.product.price.value" or "offers[0].price"
+// through decoded JSON. Array elements can also be addressed as "offers.0".
+func lookupPath(data interface{}, path string) (interface{}, error) {
+	if path == "" {
+		return nil, fmt.Errorf("empty JSON path")
+	}
+	current := data
+	for _, key := range strings.Split(strings.NewReplacer("[", ".", "]", "").Replace(path), ".") {
+		if key == "" {
+			continue
+		}
+		switch node := current.(type) {
+		case map[string]interface{}:
+			next, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("key %q not found at path %q", key, path)
+			}
+			current = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("invalid index %q at path %q", key, path)
+			}
+			current = node[i]
+		default:
+			return nil, fmt.Errorf("cannot look up %q in a scalar at path %q", key, path)
+		}
+	}
+	return current, nil
+}
@@ -2,20 +2,146 @@
 package scraper
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+
+	"price-tracker-backend/currency"
+)
+
+// Rules for choosing among the prices a selector matches
+const (
+	PickFirst = "first" // The first in document order, the default
+	PickMin   = "min"
+	PickMax   = "max"
+	PickLabel = "label" // The first whose block mentions Label
 )
 
 // PriceSelectorConfig holds selectors for different domains or general patterns
 type PriceSelectorConfig struct {
-	Domain   string // e.g., "amazon.com"
-	Selector string // goquery selector string
-	// Potentially add more fields like attribute to get, or if it's split into multiple elements
+	Domain   string `json:"domain,omitempty"` // e.g., "amazon.com"
+	Selector string `json:"selector"`         // goquery selector string
+	// Attribute holding the price, e.g. "data-price" or "value". Empty reads the
+	// element's text, or its content attribute when it has no text.
+	Attribute string `json:"attribute,omitempty"`
+
+	// Pick chooses among several matches on pages that list more than one
+	// price, e.g. variants, subscribe-and-save or used offers: "first", "min",
+	// "max" or "label"
+	Pick string `json:"pick,omitempty"`
+	// Label is the text, e.g. "Buy New", that marks the price to pick with
+	// "label", matched case-insensitively within the match's block: its largest
+	// ancestor holding no other match. LabelSelector narrows where in the block
+	// the text is looked for, e.g. ".offer-title".
+	Label         string `json:"label,omitempty"`
+	LabelSelector string `json:"labelSelector,omitempty"`
+}
+
+// Validate checks the pick rule and its label
+func (c PriceSelectorConfig) Validate() error {
+	switch c.Pick {
+	case "", PickFirst, PickMin, PickMax:
+	case PickLabel:
+		if strings.TrimSpace(c.Label) == "" {
+			return fmt.Errorf("pick %q needs a label", PickLabel)
+		}
+	default:
+		return fmt.Errorf("unknown pick %q, expected %q, %q, %q or %q", c.Pick, PickFirst, PickMin, PickMax, PickLabel)
+	}
+	if c.LabelSelector != "" {
+		if _, err := cascadia.Compile(c.LabelSelector); err != nil {
+			return fmt.Errorf("invalid labelSelector: %v", err)
+		}
+	}
+	return nil
+}
+
+// priceMatch is one element the selector matched and the price text read from it
+type priceMatch struct {
+	el   *goquery.Selection
+	text string
+}
+
+// text reads the price text of one matched element
+func (c PriceSelectorConfig) text(s *goquery.Selection) string {
+	if c.Attribute != "" {
+		return strings.TrimSpace(s.AttrOr(c.Attribute, ""))
+	}
+	if text := strings.TrimSpace(s.Text()); text != "" {
+		return text
+	}
+	// e.g. <meta itemprop="price" content="29.99">
+	return strings.TrimSpace(s.AttrOr("content", ""))
+}
+
+// matches returns every element the selector matches that has price text, in
+// document order
+func (c PriceSelectorConfig) matches(doc *goquery.Selection) []priceMatch {
+	var found []priceMatch
+	doc.Find(c.Selector).Each(func(_ int, s *goquery.Selection) {
+		if text := c.text(s); text != "" {
+			found = append(found, priceMatch{el: s, text: text})
+		}
+	})
+	return found
+}
+
+// Extract returns the price text of the element Pick chooses among those the
+// selector matches. Prices are parsed with format to compare them for "min"
+// and "max"; matches that don't parse are skipped.
+func (c PriceSelectorConfig) Extract(doc *goquery.Selection, format NumberFormat) string {
+	found := c.matches(doc)
+	if len(found) == 0 {
+		return ""
+	}
+	switch c.Pick {
+	case PickMin, PickMax:
+		best, bestPrice, parsed := found[0].text, currency.Money{}, false
+		for _, m := range found {
+			price, err := ParsePriceStringFormat(m.text, format)
+			if err != nil {
+				continue
+			}
+			if !parsed || (c.Pick == PickMin && price.Cmp(bestPrice) < 0) || (c.Pick == PickMax && price.Cmp(bestPrice) > 0) {
+				best, bestPrice, parsed = m.text, price, true
+			}
+		}
+		return best
+	case PickLabel:
+		for _, m := range found {
+			if c.labeled(m.el) {
+				return m.text
+			}
+		}
+		return ""
+	default:
+		return found[0].text
+	}
+}
+
+// labeled reports whether a match's block mentions the label
+func (c PriceSelectorConfig) labeled(el *goquery.Selection) bool {
+	block := el
+	for parent := el.Parent(); parent.Length() > 0 && parent.Find(c.Selector).Length() <= 1; parent = parent.Parent() {
+		block = parent
+	}
+	label := strings.ToLower(strings.TrimSpace(c.Label))
+	if c.LabelSelector == "" {
+		return strings.Contains(strings.ToLower(block.Text()), label)
+	}
+	found := false
+	block.Find(c.LabelSelector).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		found = strings.Contains(strings.ToLower(s.Text()), label)
+		return !found
+	})
+	return found
 }
 
 // Define some common selectors. This list needs to be expanded and refined.
@@ -33,23 +159,131 @@ var commonSelectors = []string{
 	// Add more based on target sites
 }
 
-// ScrapePrice tries to find and parse a price from a given URL.
-// It returns the price, the selector that worked, and any error.
-func ScrapePrice(urlStr string) (float64, string, error) {
-	log.Printf("Scraping URL: %s", urlStr)
-	res, err := http.Get(urlStr)
+// Phrases retailers use on sold-out product pages
+var outOfStockPhrases = []string{
+	"out of stock",
+	"currently unavailable",
+	"sold out",
+	"temporarily unavailable",
+	"no longer available",
+}
+
+// IsOutOfStockText reports whether an availability message says the item can't be bought
+func IsOutOfStockText(text string) bool {
+	text = strings.ToLower(text)
+	for _, phrase := range outOfStockPhrases {
+		if strings.Contains(text, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScraperOptions controls the HTTP requests the scraper makes
+type ScraperOptions struct {
+	UserAgent      string
+	Accept         string
+	AcceptLanguage string
+	Referer        string // Empty sends the page's own origin
+	Timeout        time.Duration
+	Transport      http.RoundTripper // Nil for the default
+	Authorization  string            // Authorization header, empty for none
+	MaxBodySize    int64             // Largest page read, in bytes; 0 for no limit
+	// CheckRedirect vets each redirect like http.Client's, nil for the default
+	CheckRedirect func(req *http.Request, via []*http.Request) error
+}
+
+// DefaultOptions are used by ScrapePrice and ScrapePriceWithSelector. They mirror the
+// headers of a regular browser, since many sites reject header-less requests.
+var DefaultOptions = ScraperOptions{
+	UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
+	Accept:         "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8",
+	AcceptLanguage: "en-US,en;q=0.5",
+	Timeout:        30 * time.Second,
+	MaxBodySize:    10 << 20,
+}
+
+// fetchDocument GETs a page with the given options and parses it as HTML
+func fetchDocument(urlStr string, opts ScraperOptions) (*goquery.Document, error) {
+	body, err := fetchPage(urlStr, opts)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	return doc, nil
+}
+
+// fetchPage GETs a page with the given options and returns the raw body
+func fetchPage(urlStr string, opts ScraperOptions) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, urlStr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	if opts.UserAgent != "" {
+		req.Header.Set("User-Agent", opts.UserAgent)
+	}
+	if opts.Accept != "" {
+		req.Header.Set("Accept", opts.Accept)
+	}
+	if opts.AcceptLanguage != "" {
+		req.Header.Set("Accept-Language", opts.AcceptLanguage)
+	}
+	if opts.Referer != "" {
+		req.Header.Set("Referer", opts.Referer)
+	} else {
+		req.Header.Set("Referer", req.URL.Scheme+"://"+req.URL.Host+"/")
+	}
+
+	if opts.Authorization != "" {
+		req.Header.Set("Authorization", opts.Authorization)
+	}
+
+	client := &http.Client{Timeout: opts.Timeout, Transport: opts.Transport, CheckRedirect: opts.CheckRedirect}
+	res, err := client.Do(req)
 	if err != nil {
-		return 0, "", fmt.Errorf("failed to get URL: %w", err)
+		return nil, fmt.Errorf("failed to get URL: %w", err)
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode != 200 {
-		return 0, "", fmt.Errorf("bad status: %s", res.Status)
+		return nil, fmt.Errorf("bad status: %s", res.Status)
+	}
+
+	body, err := readBody(res.Body, opts.MaxBodySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page: %w", err)
+	}
+	return body, nil
+}
+
+// readBody reads a response body of at most max bytes, failing rather than
+// truncating beyond that so a huge response can't exhaust memory. 0 means no limit.
+func readBody(r io.Reader, max int64) ([]byte, error) {
+	if max <= 0 {
+		return io.ReadAll(r)
+	}
+	body, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err == nil && int64(len(body)) > max {
+		return nil, fmt.Errorf("response is larger than %d bytes", max)
 	}
+	return body, err
+}
 
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+// ScrapePrice tries to find and parse a price from a given URL.
+// It returns the price, the selector that worked, and any error.
+func ScrapePrice(urlStr string) (currency.Money, string, error) {
+	return ScrapePriceWithOptions(urlStr, DefaultOptions)
+}
+
+// ScrapePriceWithOptions is ScrapePrice with explicit request options.
+func ScrapePriceWithOptions(urlStr string, opts ScraperOptions) (currency.Money, string, error) {
+	log.Printf("Scraping URL: %s", urlStr)
+	doc, err := fetchDocument(urlStr, opts)
 	if err != nil {
-		return 0, "", fmt.Errorf("failed to parse HTML: %w", err)
+		return currency.Money{}, "", err
 	}
 
 	// Try Amazon specific logic first for .a-price-whole
@@ -70,7 +304,7 @@ func ScrapePrice(urlStr string) (float64, string, error) {
 	if amazonPriceText != "" {
 		price, err := ParsePriceString(amazonPriceText)
 		if err == nil {
-			log.Printf("Found Amazon price: %f using .a-price-whole", price)
+			log.Printf("Found Amazon price: %s using .a-price-whole", price.Decimal())
 			return price, ".a-price-whole (composite)", nil
 		}
 	}
@@ -96,35 +330,36 @@ func ScrapePrice(urlStr string) (float64, string, error) {
 		if priceText != "" {
 			price, err := ParsePriceString(priceText)
 			if err == nil {
-				log.Printf("Found price: %f using selector: %s", price, selector)
+				log.Printf("Found price: %s using selector: %s", price.Decimal(), selector)
 				return price, selector, nil
 			}
 			log.Printf("Failed to parse '%s' from selector '%s': %v", priceText, selector, err)
 		}
 	}
 
-	return 0, "", fmt.Errorf("could not find or parse price on page with known selectors")
+	return currency.Money{}, "", fmt.Errorf("could not find or parse price on page with known selectors")
 }
 
 // ScrapePriceWithSelector scrapes a price from a URL using a specific selector.
-func ScrapePriceWithSelector(urlStr, selector string) (float64, error) {
-	res, err := http.Get(urlStr)
-	if err != nil {
-		return 0, fmt.Errorf("failed to get URL: %w", err)
-	}
-	defer res.Body.Close()
+func ScrapePriceWithSelector(urlStr, selector string) (currency.Money, error) {
+	return ScrapePriceWithSelectorAndOptions(urlStr, selector, DefaultOptions)
+}
 
-	if res.StatusCode != 200 {
-		return 0, fmt.Errorf("bad status: %s", res.Status)
-	}
+// ScrapePriceWithSelectorAndOptions is ScrapePriceWithSelector with explicit request options.
+func ScrapePriceWithSelectorAndOptions(urlStr, selector string, opts ScraperOptions) (currency.Money, error) {
+	return ScrapePriceWithConfig(urlStr, PriceSelectorConfig{Selector: selector}, opts)
+}
 
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+// ScrapePriceWithConfig scrapes a price from a URL using a selector config,
+// reading its attribute when one is set.
+func ScrapePriceWithConfig(urlStr string, conf PriceSelectorConfig, opts ScraperOptions) (currency.Money, error) {
+	doc, err := fetchDocument(urlStr, opts)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse HTML: %w", err)
+		return currency.Money{}, err
 	}
 
 	// Special handling for Amazon composite selector
-	if selector == ".a-price-whole (composite)" {
+	if conf.Selector == ".a-price-whole (composite)" {
 		// ... (logic for Amazon price)
 		amazonPriceText := ""
 		doc.Find(".a-price-whole").EachWithBreak(func(i int, s *goquery.Selection) bool {
@@ -144,28 +379,18 @@ func ScrapePriceWithSelector(urlStr, selector string) (float64, error) {
 		}
 	}
 
-	priceText := ""
-	doc.Find(selector).EachWithBreak(func(i int, s *goquery.Selection) bool {
-		text := strings.TrimSpace(s.Text())
-		if text != "" {
-			priceText = text
-			return false
-		}
-		if contentVal, exists := s.Attr("content"); exists && strings.TrimSpace(contentVal) != "" {
-			priceText = strings.TrimSpace(contentVal)
-			return false
-		}
-		return true
-	})
-
+	priceText := conf.Extract(doc.Selection, NumberFormat{})
 	if priceText == "" {
-		return 0, fmt.Errorf("could not find price with selector: %s", selector)
+		if conf.Attribute != "" {
+			return currency.Money{}, fmt.Errorf("could not find price in attribute %s with selector: %s", conf.Attribute, conf.Selector)
+		}
+		return currency.Money{}, fmt.Errorf("could not find price with selector: %s", conf.Selector)
 	}
 
 	return ParsePriceString(priceText)
 }
 
-func ParsePriceString(priceStr string) (float64, error) {
+func ParsePriceString(priceStr string) (currency.Money, error) {
 	// Remove currency symbols, thousands separators, etc.
 	// Be careful with different decimal separators if supporting international sites.
 	// This is a simplified parser.
@@ -184,9 +409,10 @@ func ParsePriceString(priceStr string) (float64, error) {
 		}
 	}
 
-	price, err := strconv.ParseFloat(cleanedStr, 64)
+	// Parsed as an exact decimal, so "4999.99" doesn't pick up float noise
+	price, err := currency.ParseMoney(cleanedStr, "")
 	if err != nil {
-		return 0, fmt.Errorf("could not parse '%s' (cleaned: '%s') as float: %w", priceStr, cleanedStr, err)
+		return currency.Money{}, fmt.Errorf("could not parse '%s' (cleaned: '%s') as a price: %w", priceStr, cleanedStr, err)
 	}
 	return price, nil
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newServer returns a server for handler with the configured timeouts, so slow
+// clients can't hold connections open (slowloris) and idle keep-alive
+// connections are closed. WebSocket connections aren't cut off by them:
+// net/http clears the deadlines when the upgrader hijacks the connection, and
+// the upgrader's HandshakeTimeout bounds writing its response instead.
+func newServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+	}
+}
+
+// serve starts the HTTP server, using TLS when certificates or autocert are configured
+func serve(handler http.Handler) error {
+	switch {
+	case len(cfg.AutocertDomains) > 0:
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.AutocertDomains...),
+			Cache:      autocert.DirCache(cfg.AutocertCacheDir),
+		}
+
+		// Answer ACME HTTP-01 challenges and redirect everything else to HTTPS
+		go func() {
+			log.Printf("Starting ACME challenge listener on :80...")
+			if err := newServer(":80", m.HTTPHandler(nil)).ListenAndServe(); err != nil {
+				log.Printf("ACME challenge listener stopped: %v", err)
+			}
+		}()
+
+		server := newServer(":443", handler)
+		server.TLSConfig = &tls.Config{GetCertificate: m.GetCertificate, MinVersion: tls.VersionTLS12}
+		fmt.Printf("Starting server on :443 with Let's Encrypt certificates for %v...\n", cfg.AutocertDomains)
+		return server.ListenAndServeTLS("", "")
+
+	case cfg.TLSCertFile != "" && cfg.TLSKeyFile != "":
+		fmt.Printf("Starting server on %s (TLS)...\n", cfg.ListenAddr)
+		return newServer(cfg.ListenAddr, handler).ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+
+	default:
+		if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+			log.Printf("Both TLS_CERT_FILE and TLS_KEY_FILE must be set to enable TLS; serving plain HTTP")
+		}
+		fmt.Printf("Starting server on %s...\n", cfg.ListenAddr)
+		return newServer(cfg.ListenAddr, handler).ListenAndServe()
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"price-tracker-backend/notify"
+)
+
+// configHandler tells the frontend how this server is set up so it can adapt,
+// e.g. which notification channels to offer. Only settings picked here are
+// shared; tokens, passwords, chat IDs and webhook URLs never are.
+func configHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	channels := []string{}
+	for _, n := range notifiers {
+		channels = append(channels, n.Name())
+	}
+	configured := []string{}
+	for _, dc := range domainConfigs {
+		configured = append(configured, dc.Domain)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":             true,
+		"checkInterval":       checkInterval.String(),
+		"manualCheckInterval": cfg.ManualCheckInterval.String(),
+		"wsCheckInterval":     cfg.WSCheckInterval.String(),
+		"domains": map[string]interface{}{
+			"builtin":    builtinDomains,
+			"configured": configured,
+		},
+		"notificationChannels": channels,
+		"baseCurrency":         converter.Base,
+		"locales":              notify.Locales(),
+		"defaultLocale":        cfg.NotifyLocale,
+		"maxTrackedItems":      cfg.MaxTrackedItems,
+		"minDropAmount":        cfg.MinDropAmount,
+		"maxLowestInDays":      maxLowestInDays,
+		"ingestEnabled":        cfg.IngestToken != "",
+	})
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// errBlockedTarget is wrapped by errors for scrape targets in internal networks
+var errBlockedTarget = errors.New("blocked scrape target")
+
+// Address ranges the IP predicates in blockedIP don't cover
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT, also used by some clouds internally
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // Benchmarking
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, which can reach internal IPv4 addresses
+}
+
+// blockedIP reports whether ip is loopback, private, link-local (including
+// cloud metadata at 169.254.169.254) or otherwise not a public address
+func blockedIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return true
+	}
+	addr = addr.Unmap()
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostListed reports whether host matches an entry of list, exactly or as a subdomain
+func hostListed(host string, list []string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, entry := range list {
+		entry = strings.ToLower(entry)
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// numericHost reports whether a host's last label is a number, which no real
+// top-level domain is
+func numericHost(host string) bool {
+	labels := strings.Split(strings.TrimSuffix(host, "."), ".")
+	last := strings.ToLower(labels[len(labels)-1])
+	if strings.HasPrefix(last, "0x") {
+		return true
+	}
+	return last != "" && strings.Trim(last, "0123456789") == ""
+}
+
+// resolveTarget resolves a scrape target's host and returns its addresses,
+// failing if the host is denied or any address is internal. Hosts on
+// SCRAPE_ALLOWED_HOSTS, or any host with SCRAPE_ALLOW_PRIVATE, pass unresolved.
+func resolveTarget(ctx context.Context, host string) ([]net.IP, error) {
+	if hostListed(host, cfg.ScrapeDeniedHosts) {
+		return nil, fmt.Errorf("%w: %s is on SCRAPE_DENIED_HOSTS", errBlockedTarget, host)
+	}
+	if cfg.ScrapeAllowPrivate || hostListed(host, cfg.ScrapeAllowedHosts) {
+		return nil, nil
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if blockedIP(ip) {
+			return nil, fmt.Errorf("%w: %s is not a public address", errBlockedTarget, host)
+		}
+		return []net.IP{ip}, nil
+	}
+	if numericHost(host) {
+		// Shorthand IPs such as 127.1 or 2130706433 that browsers accept
+		return nil, fmt.Errorf("%w: %s looks like an IP address", errBlockedTarget, host)
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		if blockedIP(addr.IP) {
+			return nil, fmt.Errorf("%w: %s resolves to %s, which is not a public address", errBlockedTarget, host, addr.IP)
+		}
+		ips = append(ips, addr.IP)
+	}
+	return ips, nil
+}
+
+// checkScrapeTarget rejects a user-supplied URL whose host is internal, before
+// it's tracked or checked
+func checkScrapeTarget(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err = resolveTarget(ctx, u.Hostname())
+	return err
+}
+
+// guardedDial dials only the addresses resolveTarget approved, so a host that
+// passed checkScrapeTarget can't later resolve to an internal address (DNS
+// rebinding) and direct redirects can't lead to one either. Configured proxies,
+// from SCRAPE_PROXIES or HTTPS_PROXY and HTTP_PROXY, are trusted and dialed as
+// they are; proxiedTargetGuard checks the targets sent through them.
+func guardedDial(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if proxies.isProxyAddr(addr) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := resolveTarget(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if ips == nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		for _, ip := range ips {
+			var conn net.Conn
+			if conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port)); err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+}
+
+// maxRedirects matches net/http's default limit
+const maxRedirects = 10
+
+// checkRedirect is the CheckRedirect of scrape clients: it checks every hop
+// with checkScrapeTarget, which guardedDial can't do for hops sent through a
+// proxy, and otherwise behaves like net/http's default
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if err := checkScrapeTarget(req.Context(), req.URL.String()); err != nil {
+		return err
+	}
+	// Like colly's default handler, don't send credentials to another host
+	if req.URL.Host != via[len(via)-1].URL.Host {
+		req.Header.Del("Authorization")
+	}
+	return nil
+}
+
+// proxiedTargetGuard checks each request's host with checkScrapeTarget before
+// it's handed to a proxy, which resolves and dials the target itself where
+// guardedDial can't see it. Redirect hops are separate requests, so they're
+// checked too.
+type proxiedTargetGuard struct {
+	base http.RoundTripper
+}
+
+func (t proxiedTargetGuard) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := checkScrapeTarget(req.Context(), req.URL.String()); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
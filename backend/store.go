@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	errItemNotFound = errors.New("tracked item not found")
+	errStoreFull    = errors.New("tracking limit reached")
+)
+
+// Store persists tracked items, their price history and delivered alerts.
+// All access to this state goes through the configured Store.
+type Store interface {
+	// Items
+	GetItem(id string) (TrackingRequest, error) // errItemNotFound if missing
+	ListItems() ([]TrackingRequest, error)
+	CountItems() (int, error)
+	// PutItem inserts or replaces an item. When limit > 0 and the item is new,
+	// it fails with errStoreFull if limit items are already stored.
+	PutItem(item TrackingRequest, limit int) error
+	DeleteItem(id string) (bool, error)
+	// DeleteItems removes, all or nothing, every item whose ID is in ids or that
+	// has any of tags, and returns the IDs removed
+	DeleteItems(ids, tags []string) ([]string, error)
+	// UpdateItem atomically applies changes to an item; errItemNotFound if missing
+	UpdateItem(id string, apply func(item *TrackingRequest) error) (TrackingRequest, error)
+
+	// Price history
+	AppendHistory(id string, point PricePoint) error
+	History(id string) ([]PricePoint, bool, error)
+	// PruneHistory deletes the points keep doesn't mark, oldest first as History
+	// returns them, and reports how many were removed. Points appended meanwhile
+	// are kept.
+	PruneHistory(id string, keep func(points []PricePoint) []bool) (int, error)
+	// HistoryIDs lists every ID with history, including items no longer tracked
+	HistoryIDs() ([]string, error)
+
+	// Alerts, with per-client read state
+	AddAlert(alert *PriceAlert) error // Assigns alert.Seq
+	UnreadAlerts(clientID string) ([]PriceAlert, error)
+	AckAlerts(clientID string, seq int64) (int64, error)
+
+	// Leases, so only one instance checks an item per interval. AcquireLease
+	// succeeds if the item is unleased, its lease expired, or owner already holds it.
+	AcquireLease(id, owner string, ttl time.Duration) (bool, error)
+	RenewLeases(owner string, ids []string, ttl time.Duration) error
+}
+
+var store = mustStore()
+
+func mustStore() Store {
+	s, err := newStore(cfg.StoreBackend)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// newStore builds the Store selected by STORE_BACKEND
+func newStore(backend string) (Store, error) {
+	switch backend {
+	case "", "memory":
+		return newMemoryStore(cfg.AlertLogSize), nil
+	case "postgres":
+		primary, err := newPostgresStore(cfg.DatabaseURL, cfg.AlertLogSize)
+		if err != nil {
+			return nil, err
+		}
+		return newFallbackStore(primary, cfg.AlertLogSize), nil
+	default:
+		return nil, fmt.Errorf("unknown store backend %q", backend)
+	}
+}
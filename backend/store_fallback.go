@@ -0,0 +1,452 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// fallbackStore wraps a store that can become unreachable, such as Postgres.
+// Everything written is mirrored in memory; while the primary fails, reads are
+// served from the mirror and writes are buffered, then replayed in order once
+// the primary answers again. Monitoring and alerting keep working meanwhile.
+type fallbackStore struct {
+	primary Store
+	mirror  *memoryStore
+
+	// Whether the primary has been reached since startup, see connector
+	connected bool
+
+	mu       sync.Mutex
+	seeded   bool // Whether the mirror holds the primary's items, see preload
+	degraded bool
+	since    time.Time
+	pending  []func(Store) error // Writes to replay on the primary, oldest first
+	dropped  int                 // Writes lost because the buffer was full
+}
+
+// connector is a primary store that must connect before it's used, such as
+// postgresStore, which also applies its migrations then
+type connector interface {
+	connect() error
+}
+
+// newFallbackStore starts in degraded mode, with an empty mirror, when the
+// primary can't be reached at startup; recover connects it once it's up.
+func newFallbackStore(primary Store, alertLogSize int) *fallbackStore {
+	s := &fallbackStore{primary: primary, mirror: newMemoryStore(alertLogSize), connected: true}
+	if c, ok := primary.(connector); ok {
+		if err := c.connect(); err != nil {
+			log.Printf("WARNING: store unavailable at startup, starting empty in memory and buffering writes: %v", err)
+			s.connected, s.degraded, s.since = false, true, time.Now()
+		}
+	}
+	if s.connected {
+		s.preload()
+	}
+	go s.recover()
+	return s
+}
+
+// preload copies the primary's items and their history into the mirror.
+// Items the mirror already has are newer, written while the primary was
+// unreachable, and are left alone.
+func (s *fallbackStore) preload() {
+	items, err := s.primary.ListItems()
+	if err != nil {
+		log.Printf("Failed to preload the store mirror: %v", err)
+	}
+	for _, item := range items {
+		if _, err := s.mirror.GetItem(item.ID); errors.Is(err, errItemNotFound) {
+			s.mirror.PutItem(item, 0)
+		}
+		if points, _, err := s.primary.History(item.ID); err == nil {
+			s.mirror.mu.Lock()
+			if len(points) >= len(s.mirror.history[item.ID]) {
+				s.mirror.history[item.ID] = points
+			}
+			s.mirror.mu.Unlock()
+		}
+	}
+	if err == nil {
+		s.mu.Lock()
+		s.seeded = true
+		s.mu.Unlock()
+	}
+}
+
+// storeUnavailable reports whether err means the primary couldn't be reached,
+// as opposed to an answer such as "not found"
+func storeUnavailable(err error) bool {
+	var apiErr *APIError
+	return err != nil && !errors.Is(err, errItemNotFound) && !errors.Is(err, errStoreFull) && !errors.As(err, &apiErr)
+}
+
+// Degraded reports whether the primary is unavailable, since when, and how many
+// writes are waiting to be replayed
+func (s *fallbackStore) Degraded() (bool, time.Time, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.degraded, s.since, len(s.pending)
+}
+
+func (s *fallbackStore) isDegraded() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.degraded
+}
+
+// fail switches to degraded mode after the primary returned err
+func (s *fallbackStore) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.degraded {
+		log.Printf("WARNING: store unavailable, continuing in memory and buffering writes: %v", err)
+		s.degraded, s.since = true, time.Now()
+	}
+}
+
+// buffer queues a write for replay. The oldest writes are dropped when the
+// buffer is full, which may lose history points but never blocks monitoring.
+func (s *fallbackStore) buffer(write func(Store) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, write)
+	if limit := cfg.StoreReplayBuffer; limit > 0 && len(s.pending) > limit {
+		s.pending = s.pending[1:]
+		s.dropped++
+	}
+}
+
+// requeue puts back a write whose replay failed, first in line. When the buffer
+// filled up meanwhile, it's the oldest and so the one dropped.
+func (s *fallbackStore) requeue(write func(Store) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if limit := cfg.StoreReplayBuffer; limit > 0 && len(s.pending) >= limit {
+		s.dropped++
+		return
+	}
+	s.pending = append([]func(Store) error{write}, s.pending...)
+}
+
+// recover probes the primary while degraded and replays buffered writes once
+// it answers again
+func (s *fallbackStore) recover() {
+	ticker := time.NewTicker(cfg.StoreRetryInterval)
+	defer ticker.Stop()
+
+	preloadPending := !s.connected
+	for range ticker.C {
+		if !s.isDegraded() {
+			continue
+		}
+		if !s.connected {
+			if err := s.primary.(connector).connect(); err != nil {
+				continue
+			}
+			s.connected = true
+			log.Printf("Store reachable, replaying the writes made since startup")
+		} else if _, err := s.primary.CountItems(); err != nil {
+			continue
+		}
+		replayed := 0
+		for {
+			s.mu.Lock()
+			if len(s.pending) == 0 && preloadPending {
+				// Writes made meanwhile are buffered and replayed after it
+				s.mu.Unlock()
+				s.preload()
+				preloadPending = false
+				continue
+			}
+			if len(s.pending) == 0 {
+				s.degraded = false
+				dropped, since := s.dropped, s.since
+				s.dropped = 0
+				s.mu.Unlock()
+				log.Printf("Store recovered after %s: replayed %d writes, %d dropped", time.Since(since).Round(time.Second), replayed, dropped)
+				break
+			}
+			// Taken off the queue before replaying, since buffer may drop the
+			// oldest write meanwhile
+			write := s.pending[0]
+			s.pending = s.pending[1:]
+			s.mu.Unlock()
+
+			if err := write(s.primary); storeUnavailable(err) {
+				log.Printf("Store replay interrupted, retrying later: %v", err)
+				s.requeue(write)
+				break
+			}
+			replayed++
+		}
+	}
+}
+
+// write runs op against the primary unless degraded; on failure it switches to
+// degraded mode and buffers op. The mirror is updated by the caller.
+func (s *fallbackStore) write(op func(Store) error) error {
+	if !s.isDegraded() {
+		err := op(s.primary)
+		if !storeUnavailable(err) {
+			return err
+		}
+		s.fail(err)
+	}
+	s.buffer(op)
+	return nil
+}
+
+func (s *fallbackStore) GetItem(id string) (TrackingRequest, error) {
+	if !s.isDegraded() {
+		item, err := s.primary.GetItem(id)
+		if !storeUnavailable(err) {
+			return item, err
+		}
+		s.fail(err)
+	}
+	return s.mirror.GetItem(id)
+}
+
+func (s *fallbackStore) ListItems() ([]TrackingRequest, error) {
+	if !s.isDegraded() {
+		items, err := s.primary.ListItems()
+		if !storeUnavailable(err) {
+			return items, err
+		}
+		s.fail(err)
+	}
+	return s.mirror.ListItems()
+}
+
+func (s *fallbackStore) CountItems() (int, error) {
+	if !s.isDegraded() {
+		n, err := s.primary.CountItems()
+		if !storeUnavailable(err) {
+			return n, err
+		}
+		s.fail(err)
+	}
+	return s.mirror.CountItems()
+}
+
+func (s *fallbackStore) PutItem(item TrackingRequest, limit int) error {
+	if !s.isDegraded() {
+		err := s.primary.PutItem(item, limit)
+		if !storeUnavailable(err) {
+			if err == nil {
+				s.mirror.PutItem(item, 0)
+			}
+			return err
+		}
+		s.fail(err)
+	}
+	s.mu.Lock()
+	seeded := s.seeded
+	s.mu.Unlock()
+	if _, err := s.mirror.GetItem(item.ID); !seeded && limit > 0 && err != nil {
+		// The mirror doesn't know how many items the primary holds yet
+		return newAPIError(http.StatusServiceUnavailable, ErrCodeStoreUnavailable, "The store is unavailable, new items can't be tracked until it's back")
+	}
+	if err := s.mirror.PutItem(item, limit); err != nil {
+		return err
+	}
+	s.buffer(func(p Store) error {
+		err := p.PutItem(item, limit)
+		if errors.Is(err, errStoreFull) {
+			log.Printf("Store replay: dropped item %s, the tracking limit of %d was reached", item.ID, limit)
+			s.mirror.DeleteItem(item.ID)
+			return nil
+		}
+		return err
+	})
+	return nil
+}
+
+func (s *fallbackStore) DeleteItem(id string) (bool, error) {
+	if !s.isDegraded() {
+		deleted, err := s.primary.DeleteItem(id)
+		if !storeUnavailable(err) {
+			s.mirror.DeleteItem(id)
+			return deleted, err
+		}
+		s.fail(err)
+	}
+	deleted, _ := s.mirror.DeleteItem(id)
+	s.buffer(func(p Store) error {
+		_, err := p.DeleteItem(id)
+		return err
+	})
+	return deleted, nil
+}
+
+func (s *fallbackStore) DeleteItems(ids, tags []string) ([]string, error) {
+	if !s.isDegraded() {
+		deleted, err := s.primary.DeleteItems(ids, tags)
+		if !storeUnavailable(err) {
+			s.mirror.DeleteItems(deleted, nil)
+			return deleted, err
+		}
+		s.fail(err)
+	}
+	deleted, _ := s.mirror.DeleteItems(ids, tags)
+	if len(deleted) > 0 {
+		s.buffer(func(p Store) error {
+			_, err := p.DeleteItems(deleted, nil)
+			return err
+		})
+	}
+	return deleted, nil
+}
+
+func (s *fallbackStore) UpdateItem(id string, apply func(item *TrackingRequest) error) (TrackingRequest, error) {
+	if !s.isDegraded() {
+		var applyErr error
+		item, err := s.primary.UpdateItem(id, func(item *TrackingRequest) error {
+			applyErr = apply(item)
+			return applyErr
+		})
+		if err == nil {
+			s.mirror.PutItem(item, 0)
+		}
+		if err == nil || err == applyErr || !storeUnavailable(err) {
+			return item, err
+		}
+		s.fail(err)
+	}
+	item, err := s.mirror.UpdateItem(id, apply)
+	if err != nil {
+		return item, err
+	}
+	// Replay apply rather than the result, so it doesn't overwrite changes the
+	// primary got meanwhile, e.g. from another instance
+	s.buffer(func(p Store) error {
+		var applyErr error
+		_, err := p.UpdateItem(id, func(item *TrackingRequest) error {
+			applyErr = apply(item)
+			return applyErr
+		})
+		switch {
+		case errors.Is(err, errItemNotFound):
+			// Unless the item was dropped on replay, see PutItem
+			if _, err := s.mirror.GetItem(id); err != nil {
+				return nil
+			}
+			return p.PutItem(item, 0)
+		case err != nil && err == applyErr:
+			log.Printf("Store replay: skipped an update of item %s: %v", id, err)
+			return nil
+		}
+		return err
+	})
+	return item, nil
+}
+
+func (s *fallbackStore) AppendHistory(id string, point PricePoint) error {
+	s.mirror.AppendHistory(id, point)
+	return s.write(func(p Store) error { return p.AppendHistory(id, point) })
+}
+
+func (s *fallbackStore) History(id string) ([]PricePoint, bool, error) {
+	if !s.isDegraded() {
+		points, ok, err := s.primary.History(id)
+		if !storeUnavailable(err) {
+			return points, ok, err
+		}
+		s.fail(err)
+	}
+	return s.mirror.History(id)
+}
+
+func (s *fallbackStore) HistoryIDs() ([]string, error) {
+	if !s.isDegraded() {
+		ids, err := s.primary.HistoryIDs()
+		if !storeUnavailable(err) {
+			return ids, err
+		}
+		s.fail(err)
+	}
+	return s.mirror.HistoryIDs()
+}
+
+// PruneHistory only compacts the primary; compaction can wait out an outage
+func (s *fallbackStore) PruneHistory(id string, keep func(points []PricePoint) []bool) (int, error) {
+	if s.isDegraded() {
+		return 0, nil
+	}
+	n, err := s.primary.PruneHistory(id, keep)
+	if err == nil {
+		s.mirror.PruneHistory(id, keep)
+	} else if storeUnavailable(err) {
+		s.fail(err)
+	}
+	return n, err
+}
+
+// AddAlert keeps the primary's sequence numbers when it's up. While degraded the
+// mirror numbers alerts, and replayed alerts get new numbers from the primary.
+func (s *fallbackStore) AddAlert(alert *PriceAlert) error {
+	if !s.isDegraded() {
+		err := s.primary.AddAlert(alert)
+		if !storeUnavailable(err) {
+			return err
+		}
+		s.fail(err)
+	}
+	copied := *alert
+	s.mirror.AddAlert(alert)
+	s.buffer(func(p Store) error {
+		replay := copied
+		return p.AddAlert(&replay)
+	})
+	return nil
+}
+
+func (s *fallbackStore) UnreadAlerts(clientID string) ([]PriceAlert, error) {
+	if !s.isDegraded() {
+		alerts, err := s.primary.UnreadAlerts(clientID)
+		if !storeUnavailable(err) {
+			return alerts, err
+		}
+		s.fail(err)
+	}
+	return s.mirror.UnreadAlerts(clientID)
+}
+
+func (s *fallbackStore) AckAlerts(clientID string, seq int64) (int64, error) {
+	if !s.isDegraded() {
+		acked, err := s.primary.AckAlerts(clientID, seq)
+		if !storeUnavailable(err) {
+			return acked, err
+		}
+		s.fail(err)
+	}
+	return s.mirror.AckAlerts(clientID, seq)
+}
+
+// AcquireLease falls back to local leases while degraded, so items keep being
+// checked; instances sharing the store may then check an item twice.
+func (s *fallbackStore) AcquireLease(id, owner string, ttl time.Duration) (bool, error) {
+	if !s.isDegraded() {
+		ok, err := s.primary.AcquireLease(id, owner, ttl)
+		if !storeUnavailable(err) {
+			return ok, err
+		}
+		s.fail(err)
+	}
+	return s.mirror.AcquireLease(id, owner, ttl)
+}
+
+func (s *fallbackStore) RenewLeases(owner string, ids []string, ttl time.Duration) error {
+	if !s.isDegraded() {
+		err := s.primary.RenewLeases(owner, ids, ttl)
+		if !storeUnavailable(err) {
+			return err
+		}
+		s.fail(err)
+	}
+	return s.mirror.RenewLeases(owner, ids, ttl)
+}
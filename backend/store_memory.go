@@ -0,0 +1,214 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// memoryStore keeps all state in process memory; it's lost on restart
+type memoryStore struct {
+	mu       sync.RWMutex
+	items    map[string]TrackingRequest
+	history  map[string][]PricePoint
+	alerts   []PriceAlert
+	alertSeq int64
+	acked    map[string]int64 // Client ID -> highest acknowledged alert Seq
+	leases   map[string]lease
+	maxAlert int
+}
+
+func newMemoryStore(alertLogSize int) *memoryStore {
+	return &memoryStore{
+		items:    make(map[string]TrackingRequest),
+		history:  make(map[string][]PricePoint),
+		acked:    make(map[string]int64),
+		leases:   make(map[string]lease),
+		maxAlert: alertLogSize,
+	}
+}
+
+func (s *memoryStore) GetItem(id string) (TrackingRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.items[id]
+	if !ok {
+		return item, errItemNotFound
+	}
+	return item, nil
+}
+
+func (s *memoryStore) ListItems() ([]TrackingRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	items := make([]TrackingRequest, 0, len(s.items))
+	for _, item := range s.items {
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (s *memoryStore) CountItems() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.items), nil
+}
+
+func (s *memoryStore) PutItem(item TrackingRequest, limit int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.items[item.ID]; !exists && limit > 0 && len(s.items) >= limit {
+		return errStoreFull
+	}
+	s.items[item.ID] = item
+	return nil
+}
+
+func (s *memoryStore) DeleteItem(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.items[id]
+	delete(s.items, id)
+	delete(s.leases, id)
+	return ok, nil
+}
+
+func (s *memoryStore) DeleteItems(ids, tags []string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted := make([]string, 0)
+	for id, item := range s.items {
+		if slices.Contains(ids, id) || slices.ContainsFunc(item.Tags, func(tag string) bool { return slices.Contains(tags, tag) }) {
+			delete(s.items, id)
+			delete(s.leases, id)
+			deleted = append(deleted, id)
+		}
+	}
+	return deleted, nil
+}
+
+func (s *memoryStore) UpdateItem(id string, apply func(item *TrackingRequest) error) (TrackingRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok {
+		return item, errItemNotFound
+	}
+	if err := apply(&item); err != nil {
+		return item, err
+	}
+	s.items[id] = item
+	return item, nil
+}
+
+func (s *memoryStore) AppendHistory(id string, point PricePoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.history[id] = append(s.history[id], point)
+	return nil
+}
+
+func (s *memoryStore) History(id string) ([]PricePoint, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	points, ok := s.history[id]
+	return append([]PricePoint(nil), points...), ok, nil
+}
+
+func (s *memoryStore) PruneHistory(id string, keep func(points []PricePoint) []bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	points := s.history[id]
+	if len(points) == 0 {
+		return 0, nil
+	}
+	mask := keep(append([]PricePoint(nil), points...))
+	kept := make([]PricePoint, 0, len(points))
+	for i, p := range points {
+		if mask[i] {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) == 0 {
+		delete(s.history, id)
+	} else {
+		s.history[id] = kept
+	}
+	return len(points) - len(kept), nil
+}
+
+func (s *memoryStore) HistoryIDs() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, len(s.history))
+	for id := range s.history {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (s *memoryStore) AddAlert(alert *PriceAlert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alertSeq++
+	alert.Seq = s.alertSeq
+	s.alerts = append(s.alerts, *alert)
+	// Like the postgres store, a size of 0 or less keeps every alert
+	if over := len(s.alerts) - s.maxAlert; s.maxAlert > 0 && over > 0 {
+		s.alerts = append([]PriceAlert(nil), s.alerts[over:]...)
+	}
+	return nil
+}
+
+func (s *memoryStore) UnreadAlerts(clientID string) ([]PriceAlert, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	acked := s.acked[clientID]
+	unread := make([]PriceAlert, 0)
+	for _, alert := range s.alerts {
+		if alert.Seq > acked {
+			unread = append(unread, alert)
+		}
+	}
+	return unread, nil
+}
+
+func (s *memoryStore) AckAlerts(clientID string, seq int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if seq > s.acked[clientID] {
+		s.acked[clientID] = min(seq, s.alertSeq)
+	}
+	return s.acked[clientID], nil
+}
+
+type lease struct {
+	owner string
+	until time.Time
+}
+
+func (s *memoryStore) AcquireLease(id, owner string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[id]; !ok {
+		return false, nil
+	}
+	now := time.Now()
+	if l, ok := s.leases[id]; ok && l.owner != owner && now.Before(l.until) {
+		return false, nil
+	}
+	s.leases[id] = lease{owner: owner, until: now.Add(ttl)}
+	return true, nil
+}
+
+func (s *memoryStore) RenewLeases(owner string, ids []string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until := time.Now().Add(ttl)
+	for _, id := range ids {
+		if l, ok := s.leases[id]; ok && l.owner == owner {
+			s.leases[id] = lease{owner: owner, until: until}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresStore keeps state in Postgres so several instances can share it.
+// Items, history points and alerts are stored as JSONB documents keyed by ID.
+type postgresStore struct {
+	pool     *pgxpool.Pool
+	maxAlert int
+}
+
+// Schema migrations, applied in order. Never edit a released migration; append a new one.
+var postgresMigrations = []string{
+	// 1: initial schema
+	`CREATE TABLE IF NOT EXISTS items (
+		id         TEXT PRIMARY KEY,
+		data       JSONB NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE TABLE IF NOT EXISTS price_history (
+		id          BIGSERIAL PRIMARY KEY,
+		item_id     TEXT NOT NULL,
+		recorded_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		data        JSONB NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS price_history_item_idx ON price_history (item_id, id);
+	CREATE TABLE IF NOT EXISTS alerts (
+		seq        BIGSERIAL PRIMARY KEY,
+		data       JSONB NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE TABLE IF NOT EXISTS alert_acks (
+		client_id TEXT PRIMARY KEY,
+		seq       BIGINT NOT NULL
+	);`,
+	// 2: per-item leases
+	`ALTER TABLE items
+		ADD COLUMN IF NOT EXISTS locked_by    TEXT,
+		ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;`,
+}
+
+// Serializes inserts that must respect the tracking limit across instances
+const itemLimitLockKey = 727100
+
+// newPostgresStore sets up the connection pool without connecting yet, see connect
+func newPostgresStore(dsn string, alertLogSize int) (*postgresStore, error) {
+	if dsn == "" {
+		return nil, errors.New("DATABASE_URL is required for the postgres store")
+	}
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DATABASE_URL: %w", err)
+	}
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+	}
+	return &postgresStore{pool: pool, maxAlert: alertLogSize}, nil
+}
+
+// connect checks that the database answers and applies pending migrations
+func (s *postgresStore) connect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := s.pool.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+	return s.migrate(ctx)
+}
+
+func (s *postgresStore) migrate(ctx context.Context) error {
+	if _, err := s.pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INT PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+	var current int
+	if err := s.pool.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for i := current; i < len(postgresMigrations); i++ {
+		version := i + 1
+		err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, postgresMigrations[i]); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d failed: %w", version, err)
+		}
+		log.Printf("Applied postgres migration %d", version)
+	}
+	return nil
+}
+
+func storeContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 10*time.Second)
+}
+
+func (s *postgresStore) GetItem(id string) (TrackingRequest, error) {
+	ctx, cancel := storeContext()
+	defer cancel()
+	var item TrackingRequest
+	err := s.pool.QueryRow(ctx, `SELECT data FROM items WHERE id = $1`, id).Scan(&item)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return item, errItemNotFound
+	}
+	return item, err
+}
+
+func (s *postgresStore) ListItems() ([]TrackingRequest, error) {
+	ctx, cancel := storeContext()
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `SELECT data FROM items ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	items, err := pgx.CollectRows(rows, pgx.RowTo[TrackingRequest])
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []TrackingRequest{}
+	}
+	return items, nil
+}
+
+func (s *postgresStore) CountItems() (int, error) {
+	ctx, cancel := storeContext()
+	defer cancel()
+	var n int
+	err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM items`).Scan(&n)
+	return n, err
+}
+
+func (s *postgresStore) PutItem(item TrackingRequest, limit int) error {
+	ctx, cancel := storeContext()
+	defer cancel()
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if limit > 0 {
+			if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, itemLimitLockKey); err != nil {
+				return err
+			}
+			var exists bool
+			var count int
+			err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM items WHERE id = $1), (SELECT COUNT(*) FROM items)`, item.ID).Scan(&exists, &count)
+			if err != nil {
+				return err
+			}
+			if !exists && count >= limit {
+				return errStoreFull
+			}
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO items (id, data) VALUES ($1, $2)
+			ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data, updated_at = now()`,
+			item.ID, item)
+		return err
+	})
+}
+
+func (s *postgresStore) DeleteItem(id string) (bool, error) {
+	ctx, cancel := storeContext()
+	defer cancel()
+	tag, err := s.pool.Exec(ctx, `DELETE FROM items WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (s *postgresStore) DeleteItems(ids, tags []string) ([]string, error) {
+	ctx, cancel := storeContext()
+	defer cancel()
+	if ids == nil {
+		ids = []string{}
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	// A single statement, so either every matching item is removed or none is
+	rows, err := s.pool.Query(ctx, `
+		DELETE FROM items
+		WHERE id = ANY($1) OR COALESCE(data->'tags', '[]'::jsonb) ?| $2
+		RETURNING id`, ids, tags)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+func (s *postgresStore) UpdateItem(id string, apply func(item *TrackingRequest) error) (TrackingRequest, error) {
+	ctx, cancel := storeContext()
+	defer cancel()
+	var item TrackingRequest
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `SELECT data FROM items WHERE id = $1 FOR UPDATE`, id).Scan(&item)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errItemNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := apply(&item); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `UPDATE items SET data = $2, updated_at = now() WHERE id = $1`, id, item)
+		return err
+	})
+	return item, err
+}
+
+func (s *postgresStore) AppendHistory(id string, point PricePoint) error {
+	ctx, cancel := storeContext()
+	defer cancel()
+	_, err := s.pool.Exec(ctx, `INSERT INTO price_history (item_id, data) VALUES ($1, $2)`, id, point)
+	return err
+}
+
+func (s *postgresStore) History(id string) ([]PricePoint, bool, error) {
+	ctx, cancel := storeContext()
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `SELECT data FROM price_history WHERE item_id = $1 ORDER BY id`, id)
+	if err != nil {
+		return nil, false, err
+	}
+	points, err := pgx.CollectRows(rows, pgx.RowTo[PricePoint])
+	if err != nil {
+		return nil, false, err
+	}
+	return points, len(points) > 0, nil
+}
+
+func (s *postgresStore) PruneHistory(id string, keep func(points []PricePoint) []bool) (int, error) {
+	ctx, cancel := storeContext()
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `SELECT id, data FROM price_history WHERE item_id = $1 ORDER BY id`, id)
+	if err != nil {
+		return 0, err
+	}
+	var rowIDs []int64
+	var points []PricePoint
+	for rows.Next() {
+		var rowID int64
+		var point PricePoint
+		if err := rows.Scan(&rowID, &point); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		rowIDs = append(rowIDs, rowID)
+		points = append(points, point)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(points) == 0 {
+		return 0, nil
+	}
+
+	// Only delete the rows that were read, so concurrent appends survive
+	mask := keep(points)
+	var drop []int64
+	for i, rowID := range rowIDs {
+		if !mask[i] {
+			drop = append(drop, rowID)
+		}
+	}
+	if len(drop) == 0 {
+		return 0, nil
+	}
+	tag, err := s.pool.Exec(ctx, `DELETE FROM price_history WHERE id = ANY($1)`, drop)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func (s *postgresStore) HistoryIDs() ([]string, error) {
+	ctx, cancel := storeContext()
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `SELECT DISTINCT item_id FROM price_history`)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+func (s *postgresStore) AddAlert(alert *PriceAlert) error {
+	ctx, cancel := storeContext()
+	defer cancel()
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, `SELECT nextval(pg_get_serial_sequence('alerts', 'seq'))`).Scan(&alert.Seq); err != nil {
+			return err
+		}
+		data, err := json.Marshal(alert)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO alerts (seq, data) VALUES ($1, $2)`, alert.Seq, data); err != nil {
+			return err
+		}
+		// Keep only the most recent alerts, like the in-memory log
+		if s.maxAlert > 0 {
+			_, err = tx.Exec(ctx, `DELETE FROM alerts WHERE seq <= $1`, alert.Seq-int64(s.maxAlert))
+		}
+		return err
+	})
+}
+
+func (s *postgresStore) UnreadAlerts(clientID string) ([]PriceAlert, error) {
+	ctx, cancel := storeContext()
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `
+		SELECT data FROM alerts
+		WHERE seq > COALESCE((SELECT seq FROM alert_acks WHERE client_id = $1), 0)
+		ORDER BY seq`, clientID)
+	if err != nil {
+		return nil, err
+	}
+	alerts, err := pgx.CollectRows(rows, pgx.RowTo[PriceAlert])
+	if err != nil {
+		return nil, err
+	}
+	if alerts == nil {
+		alerts = []PriceAlert{}
+	}
+	return alerts, nil
+}
+
+func (s *postgresStore) AckAlerts(clientID string, seq int64) (int64, error) {
+	ctx, cancel := storeContext()
+	defer cancel()
+	var acked int64
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO alert_acks (client_id, seq)
+		VALUES ($1, LEAST($2, (SELECT COALESCE(MAX(seq), 0) FROM alerts)))
+		ON CONFLICT (client_id) DO UPDATE SET seq = GREATEST(alert_acks.seq, EXCLUDED.seq)
+		RETURNING seq`, clientID, seq).Scan(&acked)
+	return acked, err
+}
+
+func (s *postgresStore) AcquireLease(id, owner string, ttl time.Duration) (bool, error) {
+	ctx, cancel := storeContext()
+	defer cancel()
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE items SET locked_by = $2, locked_until = now() + $3::interval
+		WHERE id = $1 AND (locked_until IS NULL OR locked_until <= now() OR locked_by = $2)`,
+		id, owner, ttl)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (s *postgresStore) RenewLeases(owner string, ids []string, ttl time.Duration) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	ctx, cancel := storeContext()
+	defer cancel()
+	_, err := s.pool.Exec(ctx, `
+		UPDATE items SET locked_until = now() + $3::interval
+		WHERE locked_by = $1 AND id = ANY($2)`,
+		owner, ids, ttl)
+	return err
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// errThrottled is wrapped by errors for scrapes skipped because the domain
+// answered 429 Too Many Requests
+var errThrottled = errors.New("domain is throttling scrapes")
+
+// throttledTransport pauses scrapes of a domain that answers 429, for as long
+// as its Retry-After asks or THROTTLE_COOLDOWN without one
+type throttledTransport struct {
+	base http.RoundTripper
+}
+
+func (t throttledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.base.RoundTrip(req)
+	if err == nil && res.StatusCode == http.StatusTooManyRequests {
+		now := time.Now()
+		wait, ok := retryAfter(res, now)
+		if !ok {
+			wait = cfg.ThrottleCooldown
+		}
+		throttleDomain(req.URL.String(), now.Add(min(wait, cfg.ThrottleMaxCooldown)))
+	}
+	return res, err
+}
+
+// retryAfter reads a Retry-After header given in seconds or as an HTTP date
+func retryAfter(res *http.Response, now time.Time) (time.Duration, bool) {
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// throttleDomain stops scrapes of rawURL's domain until the given time. The
+// pause shows in the domain's breaker at /api/stats.
+func throttleDomain(rawURL string, until time.Time) {
+	key := breakerKey(rawURL)
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+
+	b, ok := breakers[key]
+	if !ok {
+		b = &domainBreaker{State: BreakerClosed}
+		breakers[key] = b
+	}
+	if until.After(b.ThrottledUntil) {
+		log.Printf("%s answered 429 Too Many Requests; not scraping it until %s", key, until.Format(time.RFC3339))
+		b.ThrottledUntil = until
+	}
+}
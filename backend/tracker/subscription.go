@@ -0,0 +1,90 @@
+package tracker
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	webpush "github.com/SherClockHolmes/webpush-go"
+)
+
+// DefaultValidateInterval is how often a tracker's subscription is validated
+const DefaultValidateInterval = 24 * time.Hour
+
+// Reasons a tracker stops itself, passed to OnStop
+const (
+	StopInvalidSubscription = "invalid subscription"
+	StopExpiredSubscription = "expired subscription"
+	StopStaleSubscription   = "stale subscription"
+)
+
+// subscriptionGone reports whether a push service response means the
+// subscription no longer exists
+func subscriptionGone(resp *http.Response) bool {
+	return resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone)
+}
+
+// staleReason returns why the subscription shouldn't be used any more at now:
+// the browser's expiration time passed, or it's older than MaxSubscriptionAge.
+// It returns "" for a usable subscription.
+func (t *Tracker) staleReason(now time.Time) string {
+	if !t.SubscriptionExpiresAt.IsZero() && !now.Before(t.SubscriptionExpiresAt) {
+		return StopExpiredSubscription
+	}
+	if t.MaxSubscriptionAge > 0 && !t.SubscribedAt.IsZero() && now.Sub(t.SubscribedAt) >= t.MaxSubscriptionAge {
+		return StopStaleSubscription
+	}
+	return ""
+}
+
+// validateSubscription stops the tracker when its subscription is stale or,
+// with ProbeSubscription, when the push service no longer knows it. It reports
+// whether the tracker was stopped.
+func (t *Tracker) validateSubscription() bool {
+	if reason := t.staleReason(time.Now()); reason != "" {
+		log.Printf("Subscription for %s is no longer valid (%s). Stopping tracker.", t.URL, reason)
+		t.stop(reason)
+		return true
+	}
+	if !t.ProbeSubscription {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.pushTimeout())
+	defer cancel()
+	// No payload and a TTL of 0: the push service checks the subscription but
+	// drops the message unless the device is reachable right away
+	resp, err := webpush.SendNotificationWithContext(ctx, nil, &t.Subscription, &webpush.Options{
+		TTL:     0,
+		Urgency: webpush.UrgencyVeryLow,
+	})
+	if err != nil {
+		log.Printf("Failed to validate subscription for %s: %v", t.URL, err)
+		return false
+	}
+	defer resp.Body.Close()
+	if subscriptionGone(resp) {
+		log.Printf("Push service no longer knows the subscription for %s (status %d). Stopping tracker.", t.URL, resp.StatusCode)
+		t.stop(StopInvalidSubscription)
+		return true
+	}
+	return false
+}
+
+// stop ends monitoring and reports the reason to OnStop, once
+func (t *Tracker) stop(reason string) {
+	t.stopOnce.Do(func() {
+		close(t.StopChan)
+		if t.OnStop != nil {
+			t.OnStop(reason)
+		}
+	})
+}
+
+func (t *Tracker) pushTimeout() time.Duration {
+	if t.PushTimeout <= 0 {
+		return DefaultPushTimeout
+	}
+	return t.PushTimeout
+}
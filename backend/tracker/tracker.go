@@ -2,10 +2,15 @@
 package tracker
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"price-tracker-backend/currency"
+	"price-tracker-backend/notify"
 	"price-tracker-backend/scraper"
+	"sync"
 	"time"
 
 	webpush "github.com/SherClockHolmes/webpush-go"
@@ -19,39 +24,108 @@ type Tracker struct {
 	Subscription   webpush.Subscription
 	StopChan       chan struct{}
 	LastPrice      float64
+	ImageURL       string           // Product image shown in notifications, looked up on the first check if empty
+	Template       *notify.Template // Wording of price drop notifications, nil for the built-in one; see notify.LocaleTemplate
+	Locale         string           // How prices are formatted for Template, e.g. "de"
+	imageLooked    bool
+	// Send a low-priority notification when the fallback scrape switches to a new selector,
+	// since a redesigned page may mean the new selector matches the wrong element
+	NotifySelectorChange bool
+	// OnStop is called when the tracker stops itself, e.g. because its push
+	// subscription is no longer valid
+	OnStop func(reason string)
+	// PushTimeout bounds each web push send; 0 uses DefaultPushTimeout
+	PushTimeout time.Duration
+	// PriceEpsilon is how far above ThresholdPrice a price may be and still
+	// count as reaching it, absorbing float rounding; 0 uses DefaultPriceEpsilon
+	PriceEpsilon float64
+
+	// Subscriptions expire without the tracker hearing about it until a send
+	// fails. Every ValidateInterval (DefaultValidateInterval if 0) the tracker
+	// stops itself once the browser-reported SubscriptionExpiresAt has passed,
+	// or MaxSubscriptionAge after SubscribedAt. With ProbeSubscription it also
+	// sends an empty push to learn whether the push service still knows the
+	// subscription; the service worker must ignore pushes without data.
+	SubscribedAt          time.Time
+	SubscriptionExpiresAt time.Time
+	MaxSubscriptionAge    time.Duration
+	ValidateInterval      time.Duration
+	ProbeSubscription     bool
+
+	stopOnce sync.Once
+}
+
+// DefaultPushTimeout is how long a push send may take before it's abandoned
+const DefaultPushTimeout = 10 * time.Second
+
+// DefaultPriceEpsilon is half the smallest currency unit (a paisa or cent)
+const DefaultPriceEpsilon = 0.005
+
+// reachedThreshold compares with a tolerance rather than exactly, since parsed
+// prices like 4999.999999 should match a 5000 threshold
+func (t *Tracker) reachedThreshold(price float64) bool {
+	epsilon := t.PriceEpsilon
+	if epsilon <= 0 {
+		epsilon = DefaultPriceEpsilon
+	}
+	return price <= t.ThresholdPrice+epsilon
 }
 
 func (t *Tracker) StartMonitoring(interval time.Duration) {
 	log.Printf("Starting monitoring for ID %s, URL: %s, Threshold: %.2f", t.ID, t.URL, t.ThresholdPrice)
+	if t.validateSubscription() {
+		return
+	}
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
+	validateInterval := t.ValidateInterval
+	if validateInterval <= 0 {
+		validateInterval = DefaultValidateInterval
+	}
+	validate := time.NewTicker(validateInterval)
+	defer validate.Stop()
 
 	for {
 		select {
+		case <-validate.C:
+			if t.validateSubscription() {
+				return
+			}
 		case <-ticker.C:
 			log.Printf("Checking price for ID %s, URL: %s", t.ID, t.URL)
+			if t.ImageURL == "" && !t.imageLooked {
+				t.imageLooked = true
+				if image, err := scraper.ScrapeImage(t.URL); err == nil {
+					t.ImageURL = image
+				}
+			}
 			// Scrape using the initially successful selector first
-			currentPrice, err := scraper.ScrapePriceWithSelector(t.URL, t.Selector)
+			scraped, err := scraper.ScrapePriceWithSelector(t.URL, t.Selector)
 			if err != nil {
 				log.Printf("Error scraping (with specific selector) for %s: %v. Trying general scrape.", t.URL, err)
 				// Fallback to general scrape if the specific selector fails (e.g., site structure changed)
 				var newSelector string
-				currentPrice, newSelector, err = scraper.ScrapePrice(t.URL)
+				scraped, newSelector, err = scraper.ScrapePrice(t.URL)
 				if err != nil {
 					log.Printf("Error during fallback general scrape for %s: %v", t.URL, err)
 					continue // Skip this check
 				}
 				if newSelector != t.Selector && newSelector != "" {
 					log.Printf("Selector for %s changed from '%s' to '%s'", t.URL, t.Selector, newSelector)
+					if t.NotifySelectorChange {
+						t.sendLowPriorityNotification("Selector changed", fmt.Sprintf("Selector changed for %s: '%s' -> '%s' (now reading %.2f). Please verify the price is correct.", TruncateURL(t.URL, 40), t.Selector, newSelector, scraped.Float()))
+					}
 					t.Selector = newSelector // Update the selector if a new one worked
 				}
 			}
+			// The tracker still compares floats, within PriceEpsilon
+			currentPrice := scraped.Float()
 
 			log.Printf("Current price for %s: %.2f (Last: %.2f, Threshold: %.2f)", t.URL, currentPrice, t.LastPrice, t.ThresholdPrice)
 
-			if currentPrice > 0 && currentPrice < t.LastPrice && currentPrice <= t.ThresholdPrice {
+			if currentPrice > 0 && currentPrice < t.LastPrice && t.reachedThreshold(currentPrice) {
 				log.Printf("PRICE DROP ALERT for %s! New Price: %.2f (Threshold: %.2f)", t.URL, currentPrice, t.ThresholdPrice)
-				t.sendNotification(fmt.Sprintf("Price Drop! Now %.2f", currentPrice), fmt.Sprintf("Item at %s is now %.2f!", TruncateURL(t.URL, 40), currentPrice))
+				t.sendPriceDrop(currentPrice)
 				t.LastPrice = currentPrice // Update last price to avoid repeated alerts for same drop
 				// Optionally, stop tracking after one alert or make it configurable
 				// close(t.StopChan)
@@ -71,41 +145,106 @@ func (t *Tracker) StartMonitoring(interval time.Duration) {
 	}
 }
 
+// sendPriceDrop notifies about a drop to currentPrice, using Template when set
+func (t *Tracker) sendPriceDrop(currentPrice float64) {
+	if t.Template == nil {
+		t.sendNotification(fmt.Sprintf("Price Drop! Now %.2f", currentPrice), fmt.Sprintf("Item at %s is now %.2f!", TruncateURL(t.URL, 40), currentPrice))
+		return
+	}
+	data := notify.AlertData{
+		Type:          "price_drop",
+		Title:         TruncateURL(t.URL, 40),
+		URL:           t.URL,
+		ImageURL:      t.ImageURL,
+		CurrentPrice:  currentPrice,
+		PreviousPrice: t.LastPrice,
+		TargetPrice:   t.ThresholdPrice,
+
+		FormattedPrice:       currency.FormatLocale(currentPrice, "", t.Locale),
+		FormattedTargetPrice: currency.FormatLocale(t.ThresholdPrice, "", t.Locale),
+	}
+	if t.LastPrice > 0 {
+		data.PercentChange = (currentPrice - t.LastPrice) / t.LastPrice * 100
+	}
+	msg, err := t.Template.Render(data)
+	if err != nil {
+		log.Printf("Notification template failed for %s: %v", t.URL, err)
+		return
+	}
+	t.sendNotification(msg.Title, msg.Body)
+}
+
 func (t *Tracker) sendNotification(title, body string) {
+	t.push(title, body, webpush.UrgencyNormal)
+}
+
+// sendLowPriorityNotification is for informational messages that needn't wake the device
+func (t *Tracker) sendLowPriorityNotification(title, body string) {
+	t.push(title, body, webpush.UrgencyLow)
+}
+
+func (t *Tracker) push(title, body string, urgency webpush.Urgency) {
 	// Payload for the push notification
 	// Can be a simple string or a JSON object for more structured data
+	image := t.ImageURL
+	if image == "" {
+		image = "/vite.svg"
+	}
 	payload, err := json.Marshal(map[string]interface{}{
 		"title": title,
 		"body":  body,
 		"icon":  "/vite.svg", // Path relative to service worker scope
-		"url":   t.URL,       // URL to open on notification click
+		"image": image,
+		"url":   t.URL, // URL to open on notification click
 	})
 	if err != nil {
 		log.Printf("Error marshalling push payload: %v", err)
 		return
 	}
 
+	// A slow push service mustn't block the monitoring loop: give up after the
+	// timeout, or as soon as the tracker is stopped
+	timeout := t.pushTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-t.StopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
 	// Send Notification (TTL in seconds, 0 means default)
-	resp, err := webpush.SendNotification(payload, &t.Subscription, &webpush.Options{
+	resp, err := webpush.SendNotificationWithContext(ctx, payload, &t.Subscription, &webpush.Options{
 		TTL: 60 * 60, // Time To Live: 1 hour
 		// VAPIDPublicKey:  main.vapidPublicKey, // Already set globally
 		// VAPIDPrivateKey: main.vapidPrivateKey,
-		// Urgency: webpush.UrgencyHigh, // Optional
+		Urgency: urgency,
 	})
 	if err != nil {
-		log.Printf("Error sending push notification for %s: %v", t.URL, err)
-		if resp != nil {
-			log.Printf("Push server response: Status %d, Body: %s", resp.StatusCode, resp.Body)
-			// If subscription is invalid (e.g., 404, 410), we should stop tracking for this subscription
-			if resp.StatusCode == 404 || resp.StatusCode == 410 {
-				log.Printf("Subscription for %s seems invalid. Stopping tracker.", t.URL)
-				close(t.StopChan) // This will stop the goroutine
-				// TODO: Need a way to remove it from the main activeTrackers map
-			}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Printf("Push notification for %s timed out after %s, skipping it", t.URL, timeout)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			log.Printf("Push notification for %s cancelled: tracker stopped", t.URL)
+			return
 		}
+		log.Printf("Error sending push notification for %s: %v", t.URL, err)
 		return
 	}
 	defer resp.Body.Close()
+	// The push service answers 404 or 410 for subscriptions that are gone
+	if subscriptionGone(resp) {
+		log.Printf("Subscription for %s seems invalid (status %d). Stopping tracker.", t.URL, resp.StatusCode)
+		t.stop(StopInvalidSubscription)
+		return
+	}
+	if resp.StatusCode >= 300 {
+		log.Printf("Push server rejected the notification for %s: status %d", t.URL, resp.StatusCode)
+		return
+	}
 	log.Printf("Push notification sent successfully for %s! Status: %d", t.URL, resp.StatusCode)
 }
 
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+
+	"price-tracker-backend/currency"
+)
+
+// PriceTransform adjusts a scraped price before it's compared, e.g. to apply a
+// standing coupon or tax. Transforms are applied in order:
+//
+//	{"op": "multiply", "value": 1.18}  add 18% tax
+//	{"op": "add", "value": -200}       subtract a 200 coupon
+//	{"op": "round", "value": 1}        round to a multiple of value (default 0.01)
+//	{"op": "func", "name": "..."}      call a Go function registered with registerPriceTransform
+type PriceTransform struct {
+	Op    string  `json:"op"`
+	Value float64 `json:"value,omitempty"`
+	Name  string  `json:"name,omitempty"`
+}
+
+// PriceTransformFunc is a transform implemented in Go. Config can only refer to
+// these by name, so no code comes from configuration.
+type PriceTransformFunc func(price currency.Money, itemURL string) (currency.Money, error)
+
+var priceTransformFuncs = map[string]PriceTransformFunc{}
+
+// registerPriceTransform makes fn available to domain configs as {"op": "func", "name": name}
+func registerPriceTransform(name string, fn PriceTransformFunc) {
+	priceTransformFuncs[name] = fn
+}
+
+// validateTransforms reports the first transform that can't be applied
+func validateTransforms(transforms []PriceTransform) error {
+	for i, t := range transforms {
+		switch t.Op {
+		case "multiply":
+			if t.Value <= 0 {
+				return fmt.Errorf("transforms[%d]: multiply needs a positive value", i)
+			}
+		case "add":
+		case "round":
+			if t.Value < 0 {
+				return fmt.Errorf("transforms[%d]: round needs a positive step", i)
+			}
+		case "func":
+			if priceTransformFuncs[t.Name] == nil {
+				return fmt.Errorf("transforms[%d]: no transform function named %q", i, t.Name)
+			}
+		default:
+			return fmt.Errorf("transforms[%d]: unknown op %q, expected multiply, add, round or func", i, t.Op)
+		}
+	}
+	return nil
+}
+
+// applyTransforms runs a price through transforms in order, rounding to the
+// price's minor unit after each
+func applyTransforms(price currency.Money, itemURL string, transforms []PriceTransform) (currency.Money, error) {
+	for _, t := range transforms {
+		switch t.Op {
+		case "multiply":
+			price = price.Mul(t.Value)
+		case "add":
+			price = price.Add(currency.FromFloat(t.Value, price.Currency))
+		case "round":
+			step := currency.FromFloat(cmp.Or(t.Value, 0.01), price.Currency)
+			if step.Minor > 1 {
+				price.Minor = int64(math.Round(float64(price.Minor)/float64(step.Minor))) * step.Minor
+			}
+		case "func":
+			var err error
+			if price, err = priceTransformFuncs[t.Name](price, itemURL); err != nil {
+				return currency.Money{}, fmt.Errorf("price transform %s failed: %w", t.Name, err)
+			}
+		}
+	}
+	if price.Sign() <= 0 {
+		return currency.Money{}, fmt.Errorf("price transforms produced an invalid price %s", price.Decimal())
+	}
+	return price, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HTTP versions a domain config can force for scrape requests
+const (
+	HTTPVersion1 = "1.1"
+	HTTPVersion2 = "2"
+)
+
+var (
+	transports   = make(map[string]http.RoundTripper)
+	transportsMu sync.Mutex
+)
+
+// transportFor returns the HTTP transport to scrape a URL with: one restricted
+// to HTTP/1.1 or HTTP/2 when the domain config asks for it, otherwise one that
+// negotiates as usual. All of them refuse internal addresses, see guardedDial
+// and proxiedTargetGuard, go through SCRAPE_PROXIES when set and pause domains
+// that answer 429, see throttledTransport. Transports are shared so connections are reused.
+func transportFor(rawURL string) http.RoundTripper {
+	version := ""
+	if dc := domainConfigFor(rawURL); dc != nil {
+		version = dc.HTTPVersion
+	}
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	if t, ok := transports[version]; ok {
+		return t
+	}
+	t, err := newTransport(version)
+	if err != nil {
+		// Rejected when the config was loaded, but never go unguarded
+		log.Printf("Falling back to the default transport: %v", err)
+		t, _ = newTransport("")
+	}
+	var rt http.RoundTripper = t
+	if len(proxies.proxies) > 0 {
+		rt = proxiedTransport{base: t}
+	}
+	if len(proxies.proxies) > 0 || len(envProxyAddrs) > 0 {
+		rt = proxiedTargetGuard{base: rt}
+	}
+	transports[version] = throttledTransport{base: rt}
+	return transports[version]
+}
+
+func newTransport(version string) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = guardedDial(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+	t.Proxy = proxyFromContext
+	switch version {
+	case "":
+	case HTTPVersion1:
+		t.Protocols = new(http.Protocols)
+		t.Protocols.SetHTTP1(true)
+	case HTTPVersion2:
+		// Without HTTP/1 the transport fails rather than falling back to it;
+		// plain http:// URLs use HTTP/2 with prior knowledge
+		t.Protocols = new(http.Protocols)
+		t.Protocols.SetHTTP2(true)
+		t.Protocols.SetUnencryptedHTTP2(true)
+	default:
+		return nil, fmt.Errorf("unsupported httpVersion %q, expected %q or %q", version, HTTPVersion1, HTTPVersion2)
+	}
+	return t, nil
+}
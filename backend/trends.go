@@ -0,0 +1,167 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"time"
+
+	"price-tracker-backend/currency"
+)
+
+// Periods GET /api/trends reports price changes over, in days
+var trendPeriods = []int{7, 30}
+
+// Changes smaller than this, in percent, count as flat
+const trendFlatPercent = 0.5
+
+// PriceTrend is an item's price change over a period, from its price at the
+// start of the period (or its first price in it, see Partial) to its latest
+type PriceTrend struct {
+	ChangePercent float64        `json:"changePercent"`
+	Direction     string         `json:"direction"` // "up", "down" or "flat"
+	FromPrice     currency.Money `json:"fromPrice"`
+	From          string         `json:"from"` // Timestamp of FromPrice
+	// The history doesn't reach back over the whole period, so the change is
+	// only since its first price in it
+	Partial bool `json:"partial,omitempty"`
+}
+
+// priceTrend is the change over the days before now in history, which is in
+// time order, or nil without two prices to compare
+func priceTrend(history []PricePoint, days int, now time.Time) *PriceTrend {
+	since := now.AddDate(0, 0, -days)
+	var from, latest *PricePoint
+	partial := true
+	for i := range history {
+		p := &history[i]
+		ts, err := time.Parse(time.RFC3339, p.Timestamp)
+		if err != nil || p.ConvertedPrice.Sign() <= 0 || ts.After(now) {
+			continue
+		}
+		switch {
+		case !ts.After(since):
+			// The last price before the period is the one it started at
+			from, partial = p, false
+		case from == nil:
+			from = p
+		}
+		latest = p
+	}
+	if from == nil || latest == from {
+		return nil
+	}
+
+	change := currency.PercentChange(from.ConvertedPrice, latest.ConvertedPrice)
+	trend := &PriceTrend{
+		ChangePercent: math.Round(change*100) / 100,
+		Direction:     "flat",
+		FromPrice:     from.ConvertedPrice,
+		From:          from.Timestamp,
+		Partial:       partial,
+	}
+	switch {
+	case change <= -trendFlatPercent:
+		trend.Direction = "down"
+	case change >= trendFlatPercent:
+		trend.Direction = "up"
+	}
+	return trend
+}
+
+// trendsHandler returns every item's price change over the last 7 and 30 days
+// for a market overview: GET /api/trends?sort=7d lists the biggest droppers over
+// that period first. Items without enough history to compare have null trends,
+// and are listed last when sorting.
+func trendsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	sortDays := 0
+	if s := r.URL.Query().Get("sort"); s != "" {
+		for _, days := range trendPeriods {
+			if s == trendKey(days) {
+				sortDays = days
+			}
+		}
+		if sortDays == 0 {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, fmt.Sprintf("Invalid sort %q, expected \"7d\" or \"30d\"", s))
+			return
+		}
+	}
+
+	items, err := store.ListItems()
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	sortItems(items)
+
+	now := time.Now()
+	type itemTrends struct {
+		entry  map[string]interface{}
+		trends map[string]*PriceTrend
+	}
+	list := make([]itemTrends, 0, len(items))
+	for _, item := range items {
+		history, _, err := store.History(item.ID)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		code := converter.Base
+		if len(history) > 0 {
+			code = cmp.Or(code, comparisonCurrency(history[len(history)-1]))
+			history = comparableHistory(history, code)
+		}
+		trends := make(map[string]*PriceTrend, len(trendPeriods))
+		for _, days := range trendPeriods {
+			trends[trendKey(days)] = priceTrend(history, days, now)
+		}
+		entry := map[string]interface{}{
+			"id":       item.ID,
+			"title":    item.Title,
+			"url":      item.URL,
+			"currency": code,
+			"points":   len(history),
+			"trends":   trends,
+		}
+		if len(history) > 0 {
+			entry["currentPrice"] = history[len(history)-1].ConvertedPrice
+		}
+		list = append(list, itemTrends{entry, trends})
+	}
+
+	if sortDays > 0 {
+		key := trendKey(sortDays)
+		slices.SortStableFunc(list, func(a, b itemTrends) int {
+			ta, tb := a.trends[key], b.trends[key]
+			switch {
+			case ta == nil && tb == nil:
+				return 0
+			case ta == nil:
+				return 1
+			case tb == nil:
+				return -1
+			}
+			return cmp.Compare(ta.ChangePercent, tb.ChangePercent)
+		})
+	}
+
+	entries := make([]map[string]interface{}, len(list))
+	for i, it := range list {
+		entries[i] = it.entry
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"items":   entries,
+		"count":   len(entries),
+	})
+}
+
+// trendKey names a trend period, e.g. "7d"
+func trendKey(days int) string {
+	return fmt.Sprintf("%dd", days)
+}
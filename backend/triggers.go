@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"price-tracker-backend/currency"
+)
+
+// PriceTrigger is one tier of a tiered alert: when the price falls to Price,
+// an alert goes out on Channels. Each trigger fires once.
+type PriceTrigger struct {
+	Price    currency.Money `json:"price"`
+	Channels []string       `json:"channels,omitempty"` // Empty means every channel
+	Fired    bool           `json:"fired,omitempty"`
+}
+
+// ChannelDashboard is the WebSocket feed and unread-alert log; the other
+// channel names are those of the notifiers ("webhook", "telegram", "smtp")
+const ChannelDashboard = "dashboard"
+
+var knownChannels = []string{ChannelDashboard, "webhook", "telegram", "smtp"}
+
+// validateTriggers checks trigger prices and channel names and clears fired state
+func validateTriggers(triggers []PriceTrigger) error {
+	for i := range triggers {
+		if triggers[i].Price.Sign() <= 0 {
+			return fmt.Errorf("triggers[%d]: price must be greater than 0", i)
+		}
+		for _, ch := range triggers[i].Channels {
+			if !slices.Contains(knownChannels, ch) {
+				return fmt.Errorf("triggers[%d]: unknown channel %q, expected one of %v", i, ch, knownChannels)
+			}
+		}
+		triggers[i].Fired = false
+	}
+	return nil
+}
+
+// checkTriggers fires every trigger the price has crossed that hasn't fired yet.
+// Once all of them have, tracking stops unless the item continues after alerts.
+func checkTriggers(ctx context.Context, id string, alert PriceAlert, minDrop currency.Money) {
+	var fired []PriceTrigger
+	item, err := store.UpdateItem(id, func(item *TrackingRequest) error {
+		fired = nil
+		for i, t := range item.Triggers {
+			if !t.Fired && atOrBelow(alert.ConvertedPrice, t.Price.Sub(minDrop)) {
+				item.Triggers[i].Fired = true
+				fired = append(fired, t)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logf(ctx, "Failed to update triggers for %s: %v", id, err)
+		return
+	}
+
+	for _, t := range fired {
+		logf(ctx, "Trigger at %s reached for %s: %s", t.Price.Decimal(), id, alert.ConvertedPrice.Decimal())
+		tierAlert := alert
+		tierAlert.TargetPrice = t.Price
+		tierAlert.Channels = t.Channels
+		deliverAlert(ctx, tierAlert)
+	}
+
+	if continueAfterAlert(item) {
+		return
+	}
+	for _, t := range item.Triggers {
+		if !t.Fired {
+			return
+		}
+	}
+	stopAfterAlert(item, StopTriggersFired)
+}
+
+// wantsChannel reports whether an alert should go out on a channel
+func wantsChannel(alert PriceAlert, channel string) bool {
+	return len(alert.Channels) == 0 || slices.Contains(alert.Channels, channel)
+}
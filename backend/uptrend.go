@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+
+	"price-tracker-backend/currency"
+)
+
+// uptrendStart looks for the price rising UPTREND_CHECKS times in a row, each
+// change an increase, and by at least UPTREND_MIN_PERCENT in all. Checks where
+// the price didn't change are skipped, so a slow climb still counts. It
+// returns the price the rise started from, or 0 without an uptrend.
+func uptrendStart(history []PricePoint, price currency.Money) currency.Money {
+	if cfg.UptrendChecks <= 0 {
+		return currency.Money{}
+	}
+	rises, latest := 0, price
+	for i := len(history) - 1; i >= 0 && rises < cfg.UptrendChecks; i-- {
+		earlier := history[i].ConvertedPrice
+		switch {
+		case earlier.Sign() <= 0 || earlier.Cmp(latest) == 0:
+			continue
+		case earlier.Cmp(latest) > 0:
+			return currency.Money{}
+		}
+		rises++
+		latest = earlier
+	}
+	if rises < cfg.UptrendChecks || currency.PercentChange(latest, price) < cfg.UptrendMinPercent {
+		return currency.Money{}
+	}
+	return latest
+}
+
+// checkUptrend tracks whether an item's price is trending up and returns the
+// price the rise started from when the item just started trending, once per
+// rise: a drop ends it, so a later rise alerts again
+func checkUptrend(id string, history []PricePoint, price currency.Money) currency.Money {
+	start := uptrendStart(history, price)
+	became := false
+	updateItemState(id, func(item *TrackingRequest) {
+		if item.LastPrice.Sign() > 0 && price.Cmp(item.LastPrice) < 0 {
+			item.UptrendAlerted = false
+		}
+		became = start.Sign() > 0 && !item.UptrendAlerted
+		if became {
+			item.UptrendAlerted = true
+		}
+	})
+	if !became {
+		return currency.Money{}
+	}
+	return start
+}
+
+// notifyUptrend alerts that an item's price is climbing, for items to buy
+// before it rises further
+func notifyUptrend(ctx context.Context, alert PriceAlert, start currency.Money) {
+	logf(ctx, "Price for %s is trending up: %s to %s", alert.ID, start.Decimal(), alert.ConvertedPrice.Decimal())
+	alert.Type = AlertUptrend
+	alert.PreviousPrice = start
+	deliverAlert(ctx, alert)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// normalizeURL cleans up a product URL so the same product always maps to the
+// same string: Amazon URLs become https://www.amazon.<tld>/dp/<ASIN>, and other
+// URLs get a lowercase host and lose their fragment.
+func normalizeURL(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid URL %q: only http and https are supported", rawURL)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid URL %q: missing host", rawURL)
+	}
+
+	if canonical := canonicalAmazonURL(u); canonical != "" {
+		return canonical, nil
+	}
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+// canonicalAmazonURL rebuilds an Amazon product URL from its ASIN, dropping
+// tracking parameters and slugs, or returns "" if u isn't one
+func canonicalAmazonURL(u *url.URL) string {
+	asin := amazonASIN(u.String())
+	if asin == "" {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, prefix := range []string{"www.", "m.", "smile."} {
+		host = strings.TrimPrefix(host, prefix)
+	}
+	return "https://www." + host + "/dp/" + asin
+}
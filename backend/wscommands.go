@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"price-tracker-backend/currency"
+)
+
+// wsCommand is a message a WebSocket client sends, e.g. {"action":"check","id":"..."}
+type wsCommand struct {
+	Action string `json:"action"`
+	ID     string `json:"id"`
+}
+
+// handleCommand runs one client command; replies go out through c.replies
+func (c *Client) handleCommand(data []byte) {
+	var cmd wsCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		c.reply(wsError("", "", newAPIError(http.StatusBadRequest, ErrCodeInvalidBody, "Commands must be JSON such as {\"action\":\"check\",\"id\":\"...\"}")))
+		return
+	}
+	switch cmd.Action {
+	case "check":
+		c.checkCommand(cmd)
+	default:
+		c.reply(wsError(cmd.Action, cmd.ID, newAPIError(http.StatusBadRequest, ErrCodeInvalidParameter, fmt.Sprintf("Unknown action %q", cmd.Action))))
+	}
+}
+
+// checkCommand scrapes a tracked item right away and replies with its price.
+// Clients subscribed to one item may only check that item, and each client may
+// run one check at a time, at most once per WS_CHECK_INTERVAL.
+func (c *Client) checkCommand(cmd wsCommand) {
+	if cmd.ID == "" || (c.itemID != "" && cmd.ID != c.itemID) {
+		c.reply(wsError(cmd.Action, cmd.ID, newAPIError(http.StatusForbidden, ErrCodeInvalidID, "This connection can't check that item")))
+		return
+	}
+	item, err := store.GetItem(cmd.ID)
+	if errors.Is(err, errItemNotFound) {
+		err = newAPIError(http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("No tracked item with ID %q", cmd.ID))
+	}
+	if err != nil {
+		c.reply(wsError(cmd.Action, cmd.ID, err))
+		return
+	}
+
+	c.checkMu.Lock()
+	now := time.Now()
+	if c.checking || now.Before(c.nextCheck) {
+		c.checkMu.Unlock()
+		c.reply(wsError(cmd.Action, cmd.ID, newAPIError(http.StatusTooManyRequests, ErrCodeRateLimited, fmt.Sprintf("On-demand checks are limited to one every %s", cfg.WSCheckInterval))))
+		return
+	}
+	c.checking = true
+	c.nextCheck = now.Add(cfg.WSCheckInterval)
+	c.checkMu.Unlock()
+
+	go func() {
+		defer func() {
+			c.checkMu.Lock()
+			c.checking = false
+			c.checkMu.Unlock()
+		}()
+		ctx := context.WithValue(context.Background(), requestIDKey{}, newID())
+		logf(ctx, "On-demand check of %s requested over WebSocket", item.ID)
+		c.reply(checkReply(ctx, item))
+	}()
+}
+
+func checkReply(ctx context.Context, item TrackingRequest) map[string]interface{} {
+	result, err := scrapeItem(ctx, item)
+	if err != nil {
+		return wsError("check", item.ID, scrapeAPIError(err))
+	}
+	code, convertedPrice, err := normalizePrice(item.URL, result.PriceString, result.Price)
+	if err != nil {
+		return wsError("check", item.ID, newAPIError(http.StatusUnprocessableEntity, ErrCodeConversionFailed, fmt.Sprintf("Unable to convert price: %v", err)))
+	}
+	return map[string]interface{}{
+		"type":            "check_result",
+		"success":         true,
+		"id":              item.ID,
+		"currentPrice":    result.Price,
+		"priceString":     result.PriceString,
+		"currency":        code,
+		"formattedPrice":  currency.Format(result.Price.Float(), code),
+		"convertedPrice":  convertedPrice,
+		"baseCurrency":    converter.Base,
+		"method":          result.Method,
+		"listPrice":       result.ListPrice,
+		"discountPercent": result.DiscountPercent,
+		"targetPrice":     item.TargetPrice,
+		"isBelowTarget":   alertTarget(item).Sign() > 0 && atOrBelow(convertedPrice, alertTarget(item)),
+		"inStock":         result.InStock,
+		"timestamp":       time.Now().Format(time.RFC3339),
+	}
+}
+
+func wsError(action, id string, err error) map[string]interface{} {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		apiErr = newAPIError(http.StatusInternalServerError, ErrCodeInternal, err.Error())
+	}
+	return map[string]interface{}{
+		"type":    "error",
+		"success": false,
+		"action":  action,
+		"id":      id,
+		"error":   apiErr,
+	}
+}
+
+// reply queues a command response; it's dropped if the client isn't reading
+func (c *Client) reply(msg map[string]interface{}) {
+	select {
+	case c.replies <- msg:
+	default:
+		log.Printf("Dropped WebSocket reply for a client that isn't reading")
+	}
+}
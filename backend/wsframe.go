@@ -0,0 +1,49 @@
+package main
+
+import (
+	"cmp"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+
+	"price-tracker-backend/msgpack"
+)
+
+// WebSocket subprotocols selecting the frame encoding, in the server's preference
+const (
+	wsProtocolMsgpack = "msgpack"
+	wsProtocolJSON    = "json"
+)
+
+// frameCodec encodes the alerts and replies sent to a WebSocket client
+type frameCodec interface {
+	WriteFrame(conn *websocket.Conn, v interface{}) error
+}
+
+// jsonCodec sends JSON text frames, the default
+type jsonCodec struct{}
+
+func (jsonCodec) WriteFrame(conn *websocket.Conn, v interface{}) error {
+	return conn.WriteJSON(v)
+}
+
+// msgpackCodec sends the same values as MessagePack binary frames, which are
+// smaller for bandwidth-constrained clients
+type msgpackCodec struct{}
+
+func (msgpackCodec) WriteFrame(conn *websocket.Conn, v interface{}) error {
+	data, err := msgpack.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+// codecFor picks a client's frame encoding: the negotiated subprotocol, else
+// the encoding query parameter, else JSON
+func codecFor(conn *websocket.Conn, r *http.Request) frameCodec {
+	if cmp.Or(conn.Subprotocol(), r.URL.Query().Get("encoding")) == wsProtocolMsgpack {
+		return msgpackCodec{}
+	}
+	return jsonCodec{}
+}
@@ -7,6 +7,7 @@ export default function Home() {
   const [targetPrice, setTargetPrice] = useState('');
   const [loading, setLoading] = useState(false);
   const [message, setMessage] = useState('');
+  const [fieldErrors, setFieldErrors] = useState({});
   const [notificationPermission, setNotificationPermission] = useState('default');
   const [monitoring, setMonitoring] = useState(false);
   const [monitoredItems, setMonitoredItems] = useState([]);
@@ -14,6 +15,18 @@ export default function Home() {
   const [sentNotifications, setSentNotifications] = useState(new Set());
   const wsRef = useRef(null);
 
+  // Map the API's field-level validation errors onto the form inputs
+  const showFieldErrors = (data) => {
+    const errors = {};
+    for (const f of data.error?.fields || []) {
+      errors[f.field === 'targetPriceString' ? 'targetPrice' : f.field] = f.message;
+    }
+    setFieldErrors(errors);
+  };
+
+  const inputClass = (field) =>
+    `w-full px-4 py-3 border ${fieldErrors[field] ? 'border-red-500' : 'border-gray-200 dark:border-gray-600'} rounded-xl shadow-sm focus:outline-none focus:ring-2 focus:ring-blue-500 focus:border-blue-500 dark:bg-gray-700 dark:text-white transition-all duration-200`;
+
   // URL slicing function
   const sliceProductUrl = (url) => {
     try {
@@ -62,14 +75,60 @@ export default function Home() {
         try {
           const alert = JSON.parse(event.data);
           console.log('Parsed price alert:', alert);
+
+          // Baseline messages only report a new item's first price
+          if (alert.type === 'baseline') return;
+
+          // Replies to our own "check" commands
+          if (alert.type === 'check_result') {
+            setMessage(`Current price: ${alert.formattedPrice}. Target price: ₹${alert.targetPrice}.${alert.isBelowTarget ? ' 🎉 Below target!' : ''}`);
+            return;
+          }
+          if (alert.type === 'error') {
+            setMessage(`Error: ${alert.error?.message || 'Command failed'}`);
+            return;
+          }
+
+          if (alert.type === 'anomaly') {
+            setMessage(`⚠️ ${alert.title || sliceProductUrl(alert.url)} is at ${alert.formattedPrice}, far outside its usual range`);
+            return;
+          }
+
+          if (alert.type === 'needs_attention') {
+            setMessage(`🔧 No price found for ${alert.title || sliceProductUrl(alert.url)} lately; the site may have changed`);
+            return;
+          }
+
+          if (alert.type === 'new_low') {
+            setMessage(`📉 ${alert.title || sliceProductUrl(alert.url)} is at ${alert.formattedPrice}, its lowest in ${alert.days} days`);
+            return;
+          }
+
+          if (alert.type === 'group_low') {
+            setMessage(`🏷️ ${alert.title || sliceProductUrl(alert.url)} is cheapest at ${alert.retailer}: ${alert.formattedPrice}`);
+            return;
+          }
+
+          if (alert.type === 'price_uptrend') {
+            setMessage(`📈 ${alert.title || sliceProductUrl(alert.url)} is getting pricier, now ${alert.formattedPrice}`);
+            return;
+          }
+
+          if (alert.type === 'expired') {
+            setMessage(`⌛ Tracking for ${alert.title || sliceProductUrl(alert.url)} expired`);
+            loadMonitoredItems();
+            return;
+          }
           
+          const afterCoupon = alert.regularPrice ? ` after coupon${alert.couponCode ? ` ${alert.couponCode}` : ''}` : '';
+
           // Check if notification already sent for this item
           if (!sentNotifications.has(alert.ID)) {
             // Show OS notification
             if (notificationPermission === 'granted') {
               console.log('Showing OS notification...');
               new Notification('Price Alert!', {
-                body: `${sliceProductUrl(alert.URL)} price dropped to ₹${alert.currentPrice}! Target was ₹${alert.targetPrice}`,
+                body: `${sliceProductUrl(alert.URL)} price dropped to ₹${alert.currentPrice}${afterCoupon}! Target was ₹${alert.targetPrice}`,
                 icon: '/favicon.ico'
               });
             } else {
@@ -77,13 +136,13 @@ export default function Home() {
             }
             
             // Update UI message
-            setMessage(`🎉 Price Alert! ${sliceProductUrl(alert.URL)} dropped to ₹${alert.currentPrice}!`);
+            setMessage(`🎉 Price Alert! ${sliceProductUrl(alert.URL)} dropped to ₹${alert.currentPrice}${afterCoupon}!`);
             
             // Mark notification as sent
             setSentNotifications(prev => new Set([...prev, alert.ID]));
             
-            // Stop monitoring this item
-            stopMonitoring(alert.ID);
+            // The backend keeps monitoring; refresh to show the latest state
+            loadMonitoredItems();
           }
         } catch (error) {
           console.error('Error parsing WebSocket message:', error);
@@ -117,6 +176,7 @@ export default function Home() {
     e.preventDefault();
     setLoading(true);
     setMessage('');
+    setFieldErrors({});
 
     try {
       const response = await fetch('http://localhost:8080/api/check-price', {
@@ -134,20 +194,21 @@ export default function Home() {
 
       if (response.ok) {
         if (data.isBelowTarget) {
-          setMessage(`🎉 Great news! The price has dropped to ₹${data.currentPrice}! (Target: ₹${targetPrice})`);
+          setMessage(`🎉 Great news! The price has dropped to ${data.formattedPrice}! (Target: ₹${targetPrice})`);
           
           // Show OS notification if permission granted
           if (notificationPermission === 'granted') {
             new Notification('Price Alert!', {
-              body: `${sliceProductUrl(productUrl)} price dropped to ₹${data.currentPrice}! Target was ₹${targetPrice}`,
+              body: `${sliceProductUrl(productUrl)} price dropped to ${data.formattedPrice}! Target was ₹${targetPrice}`,
               icon: '/favicon.ico'
             });
           }
         } else {
-          setMessage(`Current price: ₹${data.currentPrice}. Target price: ₹${targetPrice}. No price drop detected.`);
+          setMessage(`Current price: ${data.formattedPrice}. Target price: ₹${targetPrice}. No price drop detected.`);
         }
       } else {
-        setMessage(`Error: ${data.message || 'Failed to check price'}`);
+        showFieldErrors(data);
+        setMessage(`Error: ${data.error?.message || data.message || 'Failed to check price'}`);
       }
     } catch (error) {
       setMessage(`Error: ${error.message}`);
@@ -180,19 +241,28 @@ export default function Home() {
 
       const data = await response.json();
 
+      showFieldErrors(data);
       if (response.ok) {
         setMessage(`✅ Started monitoring ${sliceProductUrl(productUrl)} for price drops below ₹${targetPrice}`);
         loadMonitoredItems();
         setProductUrl('');
         setTargetPrice('');
       } else {
-        setMessage(`Error: ${data.message || 'Failed to start monitoring'}`);
+        setMessage(`Error: ${data.error?.message || data.message || 'Failed to start monitoring'}`);
       }
     } catch (error) {
       setMessage(`Error: ${error.message}`);
     }
   };
 
+  // Ask the server to scrape a tracked item now; the result arrives over the WebSocket
+  const checkNow = (id) => {
+    if (wsRef.current?.readyState === WebSocket.OPEN) {
+      wsRef.current.send(JSON.stringify({ action: 'check', id }));
+      setMessage('Checking price...');
+    }
+  };
+
   const stopMonitoring = async (id) => {
     try {
       const response = await fetch('http://localhost:8080/api/untrack-price', {
@@ -208,8 +278,11 @@ export default function Home() {
       if (response.ok) {
         setMessage(`🛑 Stopped monitoring item`);
         loadMonitoredItems();
+      } else if (response.status === 404) {
+        // Already untracked, e.g. its target was reached
+        loadMonitoredItems();
       } else {
-        setMessage(`Error: ${data.message || 'Failed to stop monitoring'}`);
+        setMessage(`Error: ${data.error?.message || data.message || 'Failed to stop monitoring'}`);
       }
     } catch (error) {
       setMessage(`Error: ${error.message}`);
@@ -260,9 +333,12 @@ export default function Home() {
                 value={productUrl}
                 onChange={(e) => setProductUrl(e.target.value)}
                 placeholder="https://www.amazon.in/product-url"
-                className="w-full px-4 py-3 border border-gray-200 dark:border-gray-600 rounded-xl shadow-sm focus:outline-none focus:ring-2 focus:ring-blue-500 focus:border-blue-500 dark:bg-gray-700 dark:text-white transition-all duration-200"
+                className={inputClass('url')}
                 required
               />
+              {fieldErrors.url && (
+                <p className="mt-2 text-sm text-red-600 dark:text-red-400">{fieldErrors.url}</p>
+              )}
             </div>
 
             <div>
@@ -277,9 +353,12 @@ export default function Home() {
                 placeholder="Enter target price in rupees"
                 step="0.01"
                 min="0"
-                className="w-full px-4 py-3 border border-gray-200 dark:border-gray-600 rounded-xl shadow-sm focus:outline-none focus:ring-2 focus:ring-blue-500 focus:border-blue-500 dark:bg-gray-700 dark:text-white transition-all duration-200"
+                className={inputClass('targetPrice')}
                 required
               />
+              {fieldErrors.targetPrice && (
+                <p className="mt-2 text-sm text-red-600 dark:text-red-400">{fieldErrors.targetPrice}</p>
+              )}
             </div>
 
             <div className="flex space-x-4">
@@ -335,12 +414,37 @@ export default function Home() {
                   <div className="flex justify-between items-start">
                     <div className="flex-1 min-w-0">
                       <p className="text-lg font-semibold text-gray-900 dark:text-white mb-2">
-                        {sliceProductUrl(item.url)}
+                        {item.title || sliceProductUrl(item.url)}
+                        {item.isGoodDeal && (
+                          <span className="ml-2 px-2 py-0.5 text-xs font-semibold text-green-800 bg-green-100 dark:text-green-200 dark:bg-green-900 rounded-full align-middle">
+                            Good deal
+                          </span>
+                        )}
+                        {item.discountPercent >= 1 && (
+                          <span className="ml-2 px-2 py-0.5 text-xs font-semibold text-blue-800 bg-blue-100 dark:text-blue-200 dark:bg-blue-900 rounded-full align-middle">
+                            {Math.round(item.discountPercent)}% off list
+                          </span>
+                        )}
                       </p>
                       <p className="text-sm text-gray-600 dark:text-gray-400">
                         Target: ₹{item.targetPrice}
+                        {item.lastPriceString && ` · Now: ${item.lastPriceString}`}
+                        {(item.adjustmentPercent > 0 || item.adjustmentAmount > 0) && item.lastPrice > 0 &&
+                          ` (₹${item.lastPrice} after coupon${item.couponCode ? ` ${item.couponCode}` : ''})`}
                       </p>
+                      {item.note && (
+                        <p className="mt-1 text-sm italic text-gray-500 dark:text-gray-400 break-words">
+                          {item.note}
+                        </p>
+                      )}
                     </div>
+                    <button
+                      onClick={() => checkNow(item.id)}
+                      disabled={!wsConnected}
+                      className="ml-4 px-4 py-2 text-sm bg-blue-600 hover:bg-blue-700 text-white rounded-lg focus:outline-none focus:ring-2 focus:ring-blue-500 disabled:opacity-50 transition-all duration-200"
+                    >
+                      Check
+                    </button>
                     <button
                       onClick={() => stopMonitoring(item.id)}
                       className="ml-4 px-4 py-2 text-sm bg-red-600 hover:bg-red-700 text-white rounded-lg focus:outline-none focus:ring-2 focus:ring-red-500 transition-all duration-200"